@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/gen"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// genConfig generates a configure file from the headers and the sampled rows
+// of data files, and the schema of the space if the graph address is given.
+func genConfig(args []string) error {
+	fs := flag.NewFlagSet("gen-config", flag.ExitOnError)
+	var vertices, edges setFlags
+	fs.Var(&vertices, "vertex", "Data file of a tag in the form of tag=path, can be repeated")
+	fs.Var(&edges, "edge", "Data file of an edge type in the form of edge=path, can be repeated")
+	withHeader := fs.Bool("header", true, "Whether the first line of data files is the header")
+	delimiter := fs.String("delimiter", ",", "Delimiter of data files")
+	sampleRows := fs.Int("sample-rows", 100, "Number of rows sampled from each data file to infer the prop types")
+	space := fs.String("space", "", "Space to import into")
+	address := fs.String("address", "", "Graph address to query the schema of the space, the prop types are inferred from data files if it is empty")
+	user := fs.String("user", "user", "User to query the schema of the space")
+	password := fs.String("password", os.Getenv(gen.PasswordEnv), "Password to query the schema of the space, $"+gen.PasswordEnv+" by default")
+	output := fs.String("output", "", "Path to write the configure file, stdout if it is empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *space == "" {
+		return fmt.Errorf("please configure the space by -space")
+	}
+	if *sampleRows <= 0 {
+		return fmt.Errorf("Invalid -sample-rows %d, it must be positive", *sampleRows)
+	}
+
+	opts := gen.Options{
+		WithHeader: *withHeader,
+		Delimiter:  *delimiter,
+		SampleRows: *sampleRows,
+		Space:      *space,
+		Address:    *address,
+		User:       *user,
+		OutputPath: *output,
+	}
+	for _, f := range []struct {
+		kind  string
+		specs setFlags
+	}{{"vertex", vertices}, {"edge", edges}} {
+		for _, s := range f.specs {
+			kv := strings.SplitN(s, "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return fmt.Errorf("Invalid -%s %s, it must be in the form of name=path", f.kind, s)
+			}
+			opts.Files = append(opts.Files, gen.FileSpec{Kind: f.kind, Name: kv[0], Path: kv[1]})
+		}
+	}
+	if len(opts.Files) == 0 {
+		return fmt.Errorf("please configure data files by -vertex or -edge")
+	}
+
+	logger.SetOutput(os.Stderr)
+	if *address != "" {
+		schema, err := gen.QuerySchema(*address, *user, *password, *space)
+		if err != nil {
+			return err
+		}
+		opts.Schema = schema
+	}
+
+	conf, warnings, err := gen.Generate(opts)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		logger.Warn(w)
+	}
+	content, err := conf.Dump()
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(*output, content, 0644)
+}
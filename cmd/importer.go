@@ -2,6 +2,10 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/vesoft-inc/nebula-importer/pkg/cmd"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
@@ -11,15 +15,86 @@ import (
 var configuration = flag.String("config", "", "Specify importer configure file path")
 var port = flag.Int("port", -1, "HTTP server port")
 var callback = flag.String("callback", "", "HTTP server callback address")
+var auth = flag.String("auth", "", "Specify authentication configure file path of HTTP server")
+var anonymousRole = flag.String("anonymous-role", "read", "Role of the requests to HTTP server without --auth: read, submit, stop or admin")
+var workspace = flag.String("workspace", "", "Directory to store data files uploaded to HTTP server, upload is disabled if empty")
+var uploadRetention = flag.Duration("upload-retention", 24*time.Hour, "How long unused uploaded data files are kept")
+var maxUploadSize = flag.Int64("max-upload-size", 10<<30, "Maximum bytes of the request body of each upload, 0 for no limit")
+var strict = flag.Bool("strict", true, "Reject the unknown keys in configure files, -strict=false ignores them")
+var sets setFlags
+
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func init() {
+	flag.Var(&sets, "set", "Override a configure item, e.g. --set clientSettings.space=prod, can be repeated")
+}
+
+// overrides collects configure overrides from environment variables and --set
+// flags, the latter take precedence.
+func overrides(sets setFlags) ([]*config.Override, error) {
+	result, err := config.EnvOverrides(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sets {
+		o, err := config.ParseOverride(s, fmt.Sprintf("--set %s", s))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		subcommands := map[string]func([]string) error{
+			"gen-config": genConfig,
+			"migrate":    migrate,
+			"validate":   validate,
+		}
+		if f, ok := subcommands[os.Args[1]]; ok {
+			if err := f(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if port != nil && *port > 0 && callback != nil && *callback != "" {
 		// Start http server
 		svr := &web.WebServer{
-			Port:     *port,
-			Callback: *callback,
+			Port:            *port,
+			Callback:        *callback,
+			Workspace:       *workspace,
+			UploadRetention: *uploadRetention,
+			MaxUploadSize:   *maxUploadSize,
+		}
+
+		role, err := web.ParseRole(*anonymousRole)
+		if err != nil {
+			panic(err)
+		}
+		svr.AnonymousRole = role
+
+		if auth != nil && *auth != "" {
+			authConf, err := web.ParseAuthConfig(*auth)
+			if err != nil {
+				panic(err)
+			}
+			svr.Auth = authConf
 		}
 
 		svr.Start()
@@ -28,7 +103,12 @@ func main() {
 			panic("please configure yaml file")
 		}
 
-		conf, err := config.Parse(*configuration)
+		o, err := overrides(sets)
+		if err != nil {
+			panic(err)
+		}
+
+		conf, err := config.ParseWithOptions(*configuration, config.ParseOptions{Overrides: o, AllowUnknownKeys: !*strict})
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+)
+
+// migrate rewrites a configure file of an older version in the latest format.
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	conf := fs.String("config", "", "Specify the importer configure file path to migrate")
+	output := fs.String("output", "", "Write the migrated configure to this path instead of rewriting the configure file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *conf == "" {
+		return fmt.Errorf("please configure yaml file by -config")
+	}
+
+	content, warnings, err := config.MigrateFile(*conf)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+
+	switch *output {
+	case "-":
+		_, err = os.Stdout.Write(content)
+		return err
+	case "":
+		if len(warnings) == 0 {
+			fmt.Fprintf(os.Stderr, "%s is already of version %s\n", *conf, config.LatestVersion)
+			return nil
+		}
+		*output = *conf
+	}
+	if err = ioutil.WriteFile(*output, content, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Migrated configure is written to %s\n", *output)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// validate reports all the problems of a configure file, and prints the
+// effective configure with the defaults filled in.
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	conf := fs.String("config", "", "Specify the importer configure file path to validate")
+	strict := fs.Bool("strict", true, "Report the unknown keys in configure files, -strict=false ignores them")
+	var sets setFlags
+	fs.Var(&sets, "set", "Override a configure item, e.g. --set clientSettings.space=prod, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *conf == "" {
+		return fmt.Errorf("please configure yaml file by -config")
+	}
+
+	// Keep stdout for the effective configure
+	logger.SetOutput(os.Stderr)
+
+	o, err := overrides(sets)
+	if err != nil {
+		return err
+	}
+	c, errs := config.Validate(*conf, config.ParseOptions{Overrides: o, AllowUnknownKeys: !*strict})
+	if c != nil {
+		b, err := c.Dump()
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(b)
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "[ERROR] %v\n", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d problems are found in %s", len(errs), *conf)
+	}
+	fmt.Fprintf(os.Stderr, "%s is valid\n", *conf)
+	return nil
+}
@@ -0,0 +1,69 @@
+package base
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayouts are the layouts tried to infer date-timestamp props. Layouts
+// with / are not included, since / combines the labels of a header column.
+var DateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+type typeMatcher struct {
+	typ   string
+	match func(string) bool
+}
+
+func typeMatchers() []typeMatcher {
+	matchers := []typeMatcher{
+		{"int", func(s string) bool {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err == nil
+		}},
+		{"double", func(s string) bool {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		}},
+		{"bool", func(s string) bool {
+			s = strings.ToLower(s)
+			return s == "true" || s == "false"
+		}},
+	}
+	for _, layout := range DateLayouts {
+		layout := layout
+		matchers = append(matchers, typeMatcher{"date-timestamp:" + layout, func(s string) bool {
+			_, err := time.Parse(layout, s)
+			return err == nil
+		}})
+	}
+	return matchers
+}
+
+// InferType returns the prop type all the values conform to, int, double,
+// bool, date-timestamp:<layout> or string. Empty values are ignored, and string
+// is returned if there is no value at all.
+func InferType(values []string) string {
+	for _, m := range typeMatchers() {
+		matched := false
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			if !m.match(v) {
+				matched = false
+				break
+			}
+			matched = true
+		}
+		if matched {
+			return m.typ
+		}
+	}
+	return "string"
+}
@@ -0,0 +1,57 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuoteNGQL encodes the string as a double-quoted nGQL string literal. The
+// quotes and backslashes are escaped, the common control characters are
+// written as \n, \t, \r, \b and \f, and the others as octal escapes \ooo,
+// which the nGQL lexer decodes. Multi-byte UTF-8 characters are written as
+// they are.
+//
+// Go's %q isn't used since it writes \xhh, \uhhhh and \a escapes which nGQL
+// doesn't decode.
+func QuoteNGQL(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				// The bytes of multi-byte characters are all >= 0x80
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FormatVID formats the VID, which is wrapped by the function, e.g. hash or
+// uuid, if it is given.
+func FormatVID(function, vid string) string {
+	if function == "" {
+		return TryConvInt64(vid)
+	}
+	return fmt.Sprintf("%s(%s)", function, QuoteNGQL(vid))
+}
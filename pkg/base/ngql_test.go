@@ -0,0 +1,107 @@
+package base
+
+import (
+	"strings"
+	"testing"
+)
+
+// unquoteNGQL decodes the string literal as the nGQL lexer does. It mirrors
+// the escape rules of the double quoted strings in src/parser/scanner.lex of
+// nebula: \n, \t, \r, \b and \f are the control characters, \ooo is the byte
+// of one to three octal digits up to \377, a backslash followed by another
+// decimal digit is an error, and a backslash followed by any other character
+// is that character, e.g. \" and \\. The raw quotes and control characters,
+// which QuoteNGQL never writes, are rejected, more strictly than by the lexer.
+func unquoteNGQL(t *testing.T, s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		t.Fatalf("%s isn't quoted", s)
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			t.Fatalf("Unescaped quote at %d of %s", i, s)
+		}
+		if c < 0x20 || c == 0x7f {
+			t.Fatalf("Raw control character %d at %d of %s", c, i, s)
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			t.Fatalf("Trailing backslash of %s", s)
+		}
+		switch c = s[i]; {
+		case c == 'n':
+			b.WriteByte('\n')
+		case c == 't':
+			b.WriteByte('\t')
+		case c == 'r':
+			b.WriteByte('\r')
+		case c == 'b':
+			b.WriteByte('\b')
+		case c == 'f':
+			b.WriteByte('\f')
+		case c >= '0' && c <= '7':
+			val := 0
+			j := i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				val = val<<3 | int(s[j]-'0')
+			}
+			if val > 0377 {
+				t.Fatalf("Octal escape out of range at %d of %s", i, s)
+			}
+			b.WriteByte(byte(val))
+			i = j - 1
+		case c == '8' || c == '9':
+			t.Fatalf("Decimal escape at %d of %s", i, s)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func TestQuoteNGQL(t *testing.T) {
+	for _, c := range []struct {
+		value    string
+		expected string
+	}{
+		{`abc`, `"abc"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir\`, `"C:\\dir\\"`},
+		{"a\nb\r\n\tc", `"a\nb\r\n\tc"`},
+		{"\b\f\x00\x07\x1b\x7f", `"\b\f\000\007\033\177"`},
+		{"名字 ünïcödé 😀", `"名字 ünïcödé 😀"`},
+		{``, `""`},
+	} {
+		if s := QuoteNGQL(c.value); s != c.expected {
+			t.Errorf("Error quote of %q: %s, expected: %s", c.value, s, c.expected)
+		}
+	}
+
+	for _, s := range []string{
+		`'single' and "double" quotes`,
+		`\" \\" \\\"`,
+		"line1\nline2\r\n\\n",
+		"\x01\x02\x1f\x7f\t\b\f",
+		"日本語のテキスト, emoji 🚀 and combining é",
+		"\\\x00\"\n",
+	} {
+		if decoded := unquoteNGQL(t, QuoteNGQL(s)); decoded != s {
+			t.Errorf("Error round trip of %q: %q", s, decoded)
+		}
+	}
+}
+
+func TestFormatVID(t *testing.T) {
+	if s := FormatVID("", "42"); s != "42" {
+		t.Errorf("Error vid: %s", s)
+	}
+	if s := FormatVID("hash", `a"b`); s != `hash("a\"b")` {
+		t.Errorf("Error hashed vid: %s", s)
+	}
+}
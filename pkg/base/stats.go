@@ -6,6 +6,7 @@ const (
 	SUCCESS  StatType = 0
 	FAILURE  StatType = 1
 	FILEDONE StatType = 2
+	SKIPPED  StatType = 3
 )
 
 const STAT_FILEDONE string = "FILEDONE"
@@ -16,27 +17,42 @@ type Stats struct {
 	ReqTime   int64
 	BatchSize int
 	Filename  string
+	// Mapping is the schema of the file which is done, if the file has several
+	Mapping string
+	Space   string
 }
 
-func NewSuccessStats(latency int64, reqTime int64, batchSize int) Stats {
+func NewSuccessStats(latency int64, reqTime int64, batchSize int, space string) Stats {
 	return Stats{
 		Type:      SUCCESS,
 		Latency:   latency,
 		ReqTime:   reqTime,
 		BatchSize: batchSize,
+		Space:     space,
 	}
 }
 
-func NewFailureStats(batchSize int) Stats {
+func NewFailureStats(batchSize int, space string) Stats {
 	return Stats{
 		Type:      FAILURE,
 		BatchSize: batchSize,
+		Space:     space,
 	}
 }
 
-func NewFileDoneStats(filename string) Stats {
+func NewFileDoneStats(filename, mapping string) Stats {
 	return Stats{
 		Type:     FILEDONE,
 		Filename: filename,
+		Mapping:  mapping,
+	}
+}
+
+// NewSkippedStats is the number of rows skipped by the filter of a file.
+func NewSkippedStats(numRows int, space string) Stats {
+	return Stats{
+		Type:      SKIPPED,
+		BatchSize: numRows,
+		Space:     space,
 	}
 }
@@ -73,7 +73,8 @@ func IsValidType(t string) bool {
 	case "bool":
 	case "timestamp":
 	default:
-		if strings.HasPrefix(t, "date-timestamp") && len(strings.Split(t, ":")) == 2 {
+		// The layout may contain colons, e.g. date-timestamp:2006-01-02 15:04:05
+		if parts := strings.SplitN(t, ":", 2); parts[0] == "date-timestamp" && len(parts) == 2 && parts[1] != "" {
 			return true
 		}
 		return false
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"math"
 	"strconv"
-	"strings"
-	"time"
 )
 
 type Stmt struct {
@@ -42,6 +40,12 @@ func (op OpType) String() string {
 type Data struct {
 	Type   OpType
 	Record Record
+	// Source is the row the data is derived from, e.g. by exploding a column,
+	// which is written to the failed data instead of Record if it is not nil
+	Source Record
+	// SourceKey identifies the source row by its file and line number, so that
+	// it is written to the failed data once for all the data derived from it
+	SourceKey string
 }
 
 func InsertData(record Record) Data {
@@ -86,6 +90,7 @@ type ResponseData struct {
 
 type ClientRequest struct {
 	Stmt  string
+	Space string
 	ErrCh chan<- ErrData
 	Data  []Data
 }
@@ -96,6 +101,7 @@ const (
 	LABEL_SRC_VID = ":SRC_VID"
 	LABEL_DST_VID = ":DST_VID"
 	LABEL_RANK    = ":RANK"
+	LABEL_TYPE    = ":TYPE"
 	LABEL_IGNORE  = ":IGNORE"
 )
 
@@ -107,15 +113,3 @@ func TryConvInt64(cell string) string {
 		return cell
 	}
 }
-
-func TryConvDateTimestamp(cell string, format string) string {
-	f := strings.TrimSpace(format)
-	c := strings.TrimSpace(cell)
-	if len(c) < len(f) {
-		f = f[0:len(c)]
-	} else {
-		c = c[0:len(f)]
-	}
-	tm, _ := time.Parse(f, c)
-	return fmt.Sprint(tm.Unix())
-}
@@ -4,8 +4,8 @@ import (
 	nebula "github.com/vesoft-inc/nebula-go"
 )
 
-func NewNebulaConnection(addr, user, password string) (*nebula.GraphClient, error) {
-	client, err := nebula.NewClient(addr)
+func NewNebulaConnection(addr, user, password string, opts ...nebula.GraphOption) (*nebula.GraphClient, error) {
+	client, err := nebula.NewClient(addr, opts...)
 	if err != nil {
 		return nil, err
 	}
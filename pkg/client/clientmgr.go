@@ -1,43 +1,80 @@
 package client
 
 import (
+	"fmt"
+
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
 	"github.com/vesoft-inc/nebula-importer/pkg/logger"
 )
 
+// NebulaClientMgr manages a client pool for each group of files sharing the
+// same client settings.
 type NebulaClientMgr struct {
 	config *config.NebulaClientSettings
-	pool   *ClientPool
+	pools  map[string]*ClientPool
+	logger *logger.Logger
 }
 
-func NewNebulaClientMgr(settings *config.NebulaClientSettings, statsCh chan<- base.Stats) (*NebulaClientMgr, error) {
+func NewNebulaClientMgr(settings *config.NebulaClientSettings, files []*config.File, statsCh chan<- base.Stats, l *logger.Logger) (*NebulaClientMgr, error) {
 	mgr := NebulaClientMgr{
 		config: settings,
+		pools:  make(map[string]*ClientPool),
+		logger: l,
 	}
 
-	if pool, err := NewClientPool(settings, statsCh); err != nil {
-		return nil, err
-	} else {
+	if len(files) == 0 {
+		files = []*config.File{nil}
+	}
+	for _, f := range files {
+		s := settings.ForFile(f)
+		key := poolKey(s)
+		if _, ok := mgr.pools[key]; ok {
+			continue
+		}
+		pool, err := NewClientPool(s, statsCh, l)
+		if err != nil {
+			mgr.Close()
+			return nil, err
+		}
+		mgr.pools[key] = pool
 		if err := pool.Init(); err != nil {
+			mgr.Close()
 			return nil, err
 		}
-		mgr.pool = pool
+		mgr.logger.Infof("Create %d Nebula Graph clients, retry: %d, timeout: %s", pool.concurrency, pool.retry, timeoutString(s))
 	}
 
-	logger.Infof("Create %d Nebula Graph clients", mgr.GetNumConnections())
-
 	return &mgr, nil
 }
 
+func poolKey(s *config.NebulaClientSettings) string {
+	return fmt.Sprintf("%d/%d/%d/%s", *s.Retry, *s.Concurrency, *s.ChannelBufferSize, timeoutString(s))
+}
+
+func timeoutString(s *config.NebulaClientSettings) string {
+	if d := s.TimeoutDuration(); d > 0 {
+		return d.String()
+	}
+	return "default"
+}
+
 func (m *NebulaClientMgr) Close() {
-	m.pool.Close()
+	for _, pool := range m.pools {
+		pool.Close()
+	}
+}
+
+func (m *NebulaClientMgr) pool(file *config.File) *ClientPool {
+	return m.pools[poolKey(m.config.ForFile(file))]
 }
 
-func (m *NebulaClientMgr) GetRequestChans() []chan base.ClientRequest {
-	return m.pool.requestChs
+// GetRequestChans returns the request channels of the clients of the file.
+func (m *NebulaClientMgr) GetRequestChans(file *config.File) []chan base.ClientRequest {
+	return m.pool(file).requestChs
 }
 
-func (m *NebulaClientMgr) GetNumConnections() int {
-	return len(m.pool.requestChs)
+// GetNumConnections returns the number of the clients of the file.
+func (m *NebulaClientMgr) GetNumConnections(file *config.File) int {
+	return len(m.pool(file).requestChs)
 }
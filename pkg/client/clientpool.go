@@ -19,23 +19,31 @@ type ClientPool struct {
 	statsCh     chan<- base.Stats
 	Conns       []*nebula.GraphClient
 	requestChs  []chan base.ClientRequest
+	logger      *logger.Logger
 }
 
-func NewClientPool(settings *config.NebulaClientSettings, statsCh chan<- base.Stats) (*ClientPool, error) {
+func NewClientPool(settings *config.NebulaClientSettings, statsCh chan<- base.Stats, l *logger.Logger) (*ClientPool, error) {
 	pool := ClientPool{
-		space:   *settings.Space,
 		statsCh: statsCh,
+		logger:  l,
+	}
+	if settings.Space != nil {
+		pool.space = *settings.Space
 	}
 	addrs := strings.Split(*settings.Connection.Address, ",")
 	pool.retry = *settings.Retry
 	pool.concurrency = (*settings.Concurrency) * len(addrs)
 	pool.Conns = make([]*nebula.GraphClient, pool.concurrency)
 	pool.requestChs = make([]chan base.ClientRequest, pool.concurrency)
+	var opts []nebula.GraphOption
+	if timeout := settings.TimeoutDuration(); timeout > 0 {
+		opts = append(opts, nebula.WithTimeout(timeout))
+	}
 
 	j := 0
 	for _, addr := range addrs {
 		for i := 0; i < *settings.Concurrency; i++ {
-			if conn, err := NewNebulaConnection(strings.TrimSpace(addr), *settings.Connection.User, *settings.Connection.Password); err != nil {
+			if conn, err := NewNebulaConnection(strings.TrimSpace(addr), *settings.Connection.User, *settings.Connection.Password, opts...); err != nil {
 				return nil, err
 			} else {
 				pool.Conns[j] = conn
@@ -54,10 +62,10 @@ func (p *ClientPool) Close() {
 	for i := 0; i < p.concurrency; i++ {
 		if p.Conns[i] != nil {
 			if resp, err := p.Conns[i].Execute(stmt); err != nil {
-				logger.Errorf("Client %d fails to open compaction option when close connection, error: %s", i, err)
+				p.logger.Errorf("Client %d fails to open compaction option when close connection, error: %s", i, err)
 			} else {
 				if resp.GetErrorCode() != graph.ErrorCode_SUCCEEDED {
-					logger.Errorf("Client %d fails to open compaction option when close connection, error code: %v, message: %s", i, resp.GetErrorCode(), resp.GetErrorMsg())
+					p.logger.Errorf("Client %d fails to open compaction option when close connection, error code: %v, message: %s", i, resp.GetErrorCode(), resp.GetErrorMsg())
 				}
 			}
 			p.Conns[i].Disconnect()
@@ -69,7 +77,10 @@ func (p *ClientPool) Close() {
 }
 
 func (p *ClientPool) Init() error {
-	stmt := fmt.Sprintf("USE %s; UPDATE CONFIGS storage:wal_ttl=3600; UPDATE CONFIGS storage:rocksdb_column_family_options = { disable_auto_compactions = true };", p.space)
+	stmt := "UPDATE CONFIGS storage:wal_ttl=3600; UPDATE CONFIGS storage:rocksdb_column_family_options = { disable_auto_compactions = true };"
+	if p.space != "" {
+		stmt = fmt.Sprintf("USE %s; %s", p.space, stmt)
+	}
 	for i := 0; i < p.concurrency; i++ {
 		if resp, err := p.Conns[i].Execute(stmt); err != nil {
 			return err
@@ -84,6 +95,9 @@ func (p *ClientPool) Init() error {
 }
 
 func (p *ClientPool) startWorker(i int) {
+	// space is the space the connection is using, the statements of other
+	// spaces are prefixed by USE
+	space := p.space
 	for {
 		data, ok := <-p.requestChs[i]
 		if !ok {
@@ -95,12 +109,17 @@ func (p *ClientPool) startWorker(i int) {
 			continue
 		}
 
+		stmt := data.Stmt
+		if data.Space != "" && data.Space != space {
+			stmt = fmt.Sprintf("USE %s; %s", data.Space, stmt)
+		}
+
 		now := time.Now()
 
 		var err error = nil
 		var resp *graph.ExecutionResponse = nil
 		for retry := p.retry; retry > 0; retry-- {
-			resp, err = p.Conns[i].Execute(data.Stmt)
+			resp, err = p.Conns[i].Execute(stmt)
 			if err == nil && resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED {
 				break
 			}
@@ -108,21 +127,26 @@ func (p *ClientPool) startWorker(i int) {
 		}
 
 		if err != nil {
-			err = fmt.Errorf("Client %d fail to execute: %s, Error: %s", i, data.Stmt, err.Error())
+			err = fmt.Errorf("Client %d fail to execute: %s, Error: %s", i, stmt, err.Error())
 		} else {
 			if resp.GetErrorCode() != graph.ErrorCode_SUCCEEDED {
-				err = fmt.Errorf("Client %d fail to execute: %s, ErrMsg: %s, ErrCode: %v", i, data.Stmt, resp.GetErrorMsg(), resp.GetErrorCode())
+				err = fmt.Errorf("Client %d fail to execute: %s, ErrMsg: %s, ErrCode: %v", i, stmt, resp.GetErrorMsg(), resp.GetErrorCode())
 			}
 		}
 
 		if err != nil {
+			// The space of the connection is unknown if the statement fails
+			space = ""
 			data.ErrCh <- base.ErrData{
 				Error: err,
 				Data:  data.Data,
 			}
 		} else {
 			timeInMs := time.Since(now).Nanoseconds() / 1e3
-			p.statsCh <- base.NewSuccessStats(int64(resp.GetLatencyInUs()), timeInMs, len(data.Data))
+			if data.Space != "" {
+				space = data.Space
+			}
+			p.statsCh <- base.NewSuccessStats(int64(resp.GetLatencyInUs()), timeInMs, len(data.Data), data.Space)
 		}
 	}
 }
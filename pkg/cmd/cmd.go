@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
 	"github.com/vesoft-inc/nebula-importer/pkg/client"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
 	"github.com/vesoft-inc/nebula-importer/pkg/errhandler"
@@ -12,74 +16,182 @@ import (
 	"github.com/vesoft-inc/nebula-importer/pkg/stats"
 )
 
+// clientMgr sends the requests of the files to the Nebula Graph clients.
+type clientMgr interface {
+	GetRequestChans(file *config.File) []chan base.ClientRequest
+	GetNumConnections(file *config.File) int
+	Close()
+}
+
+// newClientMgr connects to Nebula Graph, the tests replace it to run without
+// a Nebula Graph service.
+var newClientMgr = func(settings *config.NebulaClientSettings, files []*config.File, statsCh chan<- base.Stats, l *logger.Logger) (clientMgr, error) {
+	return client.NewNebulaClientMgr(settings, files, statsCh, l)
+}
+
 type Runner struct {
 	err       error
+	readErr   error
 	Readers   []*reader.FileReader
 	NumFailed int64
+	// LogWriter receives the task logs besides stdout and the log file
+	LogWriter io.Writer
+	statsMgr  *stats.StatsMgr
+	stopped   bool
+	mux       sync.Mutex
+}
+
+// Stop stops reading the files of the running stage, and the later stages
+// are not started.
+func (r *Runner) Stop() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.stopped = true
+	for _, fr := range r.Readers {
+		fr.Stop()
+	}
+}
+
+func (r *Runner) isStopped() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.stopped
+}
+
+func fileIndex(files []*config.File, file *config.File) int {
+	for i, f := range files {
+		if f == file {
+			return i
+		}
+	}
+	return -1
 }
 
 func (r *Runner) Error() error {
 	return r.err
 }
 
+// Stats returns the progress of the running task, false if it has not started.
+func (r *Runner) Stats() (stats.Snapshot, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.statsMgr == nil {
+		return stats.Snapshot{}, false
+	}
+	return r.statsMgr.Snapshot(), true
+}
+
 func (r *Runner) Run(yaml *config.YAMLConfig) {
+	var l *logger.Logger
+	if r.LogWriter != nil {
+		l = logger.NewFileLogger(*yaml.LogPath, r.LogWriter)
+	} else {
+		l = logger.NewFileLogger(*yaml.LogPath)
+	}
+	defer l.Close()
+
 	now := time.Now()
 	defer func() {
 		if re := recover(); re != nil {
 			r.err = fmt.Errorf("%v", re)
 		} else {
 			if r.err == nil {
-				logger.Infof("Finish import data, consume time: %.2fs", time.Since(now).Seconds())
+				l.Infof("Finish import data, consume time: %.2fs", time.Since(now).Seconds())
 			}
 		}
 	}()
 
-	logger.Init(*yaml.LogPath)
-
-	statsMgr := stats.NewStatsMgr(len(yaml.Files))
+	statsMgr := stats.NewStatsMgr(yaml.NumMappings(), l)
 	defer statsMgr.Close()
+	r.mux.Lock()
+	r.statsMgr = statsMgr
+	r.mux.Unlock()
 
-	clientMgr, err := client.NewNebulaClientMgr(yaml.NebulaClientSettings, statsMgr.StatsCh)
+	clientMgr, err := newClientMgr(yaml.NebulaClientSettings, yaml.Files, statsMgr.StatsCh, l)
 	if err != nil {
 		r.err = err
 		return
 	}
 	defer clientMgr.Close()
 
-	errHandler := errhandler.New(statsMgr.StatsCh)
-
-	freaders := make([]*reader.FileReader, len(yaml.Files))
+	errHandler := errhandler.New(statsMgr.StatsCh, l)
 
-	for i, file := range yaml.Files {
-		// TODO: skip files with error
-		errCh, err := errHandler.Init(file, clientMgr.GetNumConnections())
-		if err != nil {
-			r.err = err
+	var reading sync.WaitGroup
+	stages := yaml.Stages()
+	for i, files := range stages {
+		if r.isStopped() {
+			r.err = fmt.Errorf("The import is stopped before stage %d", i)
 			return
 		}
+		if len(stages) > 1 {
+			names := make([]string, len(files))
+			for j, file := range files {
+				names[j] = file.String()
+			}
+			l.Infof("Start stage %d: %s", i, strings.Join(names, ", "))
+		}
 
-		if fr, err := reader.New(i, file, clientMgr.GetRequestChans(), errCh); err != nil {
-			r.err = err
-			return
-		} else {
-			go func() {
-				if err := fr.Read(); err != nil {
-					logger.Error(err)
+		freaders := make([]*reader.FileReader, 0, len(files))
+		for _, file := range files {
+			// TODO: skip files with error
+			var errChs []chan base.ErrData
+			for _, schema := range file.Mappings() {
+				errCh, err := errHandler.Init(file, schema, clientMgr.GetNumConnections(file))
+				if err != nil {
+					r.err = err
+					return
 				}
-			}()
-			freaders[i] = fr
+				errChs = append(errChs, errCh)
+			}
+
+			if fr, err := reader.New(fileIndex(yaml.Files, file), file, clientMgr.GetRequestChans(file), errChs, statsMgr.StatsCh, l); err != nil {
+				r.err = err
+				return
+			} else {
+				reading.Add(1)
+				go func() {
+					defer reading.Done()
+					if err := fr.Read(); err != nil {
+						l.Error(err)
+						r.mux.Lock()
+						if r.readErr == nil {
+							r.readErr = err
+						}
+						r.mux.Unlock()
+					}
+				}()
+				freaders = append(freaders, fr)
+			}
 		}
-	}
 
-	r.Readers = freaders
+		r.mux.Lock()
+		r.Readers = freaders
+		if r.stopped {
+			for _, fr := range freaders {
+				fr.Stop()
+			}
+		}
+		r.mux.Unlock()
+
+		for _, file := range files {
+			for range file.Mappings() {
+				<-statsMgr.FileDoneCh
+			}
+		}
+	}
 
 	<-statsMgr.DoneCh
+	reading.Wait()
 
+	r.mux.Lock()
 	r.Readers = nil
+	r.mux.Unlock()
 	r.NumFailed = statsMgr.NumFailed
 
 	if statsMgr.NumFailed > 0 {
 		r.err = fmt.Errorf("Total %d lines fail to insert to nebula", statsMgr.NumFailed)
+	} else if r.readErr != nil {
+		r.err = r.readErr
 	} else {
 		r.err = nil
 	}
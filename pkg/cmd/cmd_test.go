@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// fakeClients records the statements instead of sending them to Nebula Graph.
+type fakeClients struct {
+	requestCh chan base.ClientRequest
+	done      chan struct{}
+	mux       sync.Mutex
+	stmts     []string
+}
+
+func newFakeClients() *fakeClients {
+	c := &fakeClients{requestCh: make(chan base.ClientRequest, 16), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for req := range c.requestCh {
+			if req.Stmt == base.STAT_FILEDONE {
+				req.ErrCh <- base.ErrData{Error: nil}
+				continue
+			}
+			c.mux.Lock()
+			c.stmts = append(c.stmts, req.Stmt)
+			c.mux.Unlock()
+		}
+	}()
+	return c
+}
+
+func (c *fakeClients) GetRequestChans(file *config.File) []chan base.ClientRequest {
+	return []chan base.ClientRequest{c.requestCh}
+}
+
+func (c *fakeClients) GetNumConnections(file *config.File) int {
+	return 1
+}
+
+func (c *fakeClients) Close() {
+	close(c.requestCh)
+	<-c.done
+}
+
+const stagesConfig = `
+version: v1rc2
+clientSettings:
+  concurrency: 1
+  space: test
+  connection:
+    user: user
+    password: password
+    address: 127.0.0.1:3699
+logPath: %[1]s/err/test.log
+files:
+  - name: course
+    path: ./course.csv
+    failDataPath: %[1]s/err/course.csv
+    batchSize: 1
+    type: csv
+    csv:
+      withHeader: false
+      withLabel: false
+    schema:
+      type: vertex
+      vertex:
+        tags:
+          - name: course
+            props:
+              - name: name
+                type: string
+  - path: ./choose.csv
+    failDataPath: %[1]s/err/choose.csv
+    batchSize: 1
+    dependsOn: [course]
+    type: csv
+    csv:
+      withHeader: false
+      withLabel: false
+    schema:
+      type: edge
+      edge:
+        name: choose
+        withRanking: false
+        props:
+          - name: grade
+            type: int
+`
+
+// runStages runs the configuration of stagesConfig with the fake clients, the
+// data file of the first stage is removed before running if missing is true.
+func runStages(t *testing.T, missing bool) (*Runner, []string) {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"config.yaml": fmt.Sprintf(stagesConfig, dir),
+		"course.csv":  "101,Math\n102,English\n",
+		"choose.csv":  "200,101,5\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf, err := config.Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing {
+		os.Remove(filepath.Join(dir, "course.csv"))
+	}
+
+	clients := newFakeClients()
+	defer func(f func(*config.NebulaClientSettings, []*config.File, chan<- base.Stats, *logger.Logger) (clientMgr, error)) {
+		newClientMgr = f
+	}(newClientMgr)
+	newClientMgr = func(*config.NebulaClientSettings, []*config.File, chan<- base.Stats, *logger.Logger) (clientMgr, error) {
+		return clients, nil
+	}
+
+	r := &Runner{LogWriter: ioutil.Discard}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(conf)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The import doesn't finish")
+	}
+	clients.mux.Lock()
+	defer clients.mux.Unlock()
+	return r, clients.stmts
+}
+
+func TestRunStages(t *testing.T) {
+	r, stmts := runStages(t, false)
+	if err := r.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 3 {
+		t.Fatalf("Error statements: %q", stmts)
+	}
+	for i, prefix := range []string{"INSERT VERTEX", "INSERT VERTEX", "INSERT EDGE"} {
+		if !strings.HasPrefix(stmts[i], prefix) {
+			t.Errorf("The edges should be inserted after the vertices: %q", stmts)
+			break
+		}
+	}
+}
+
+func TestRunStagesReadFailure(t *testing.T) {
+	r, stmts := runStages(t, true)
+	if err := r.Error(); err == nil || !strings.Contains(err.Error(), "course.csv") {
+		t.Errorf("The import should fail by the file which can't be read: %v", err)
+	}
+	if len(stmts) != 1 || !strings.HasPrefix(stmts[0], "INSERT EDGE") {
+		t.Errorf("The later stage should run after the failed file: %q", stmts)
+	}
+}
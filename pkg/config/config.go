@@ -4,41 +4,82 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
 	"github.com/vesoft-inc/nebula-importer/pkg/logger"
-	yaml "gopkg.in/yaml.v2"
 )
 
 type NebulaClientConnection struct {
-	User     *string `json:"user" yaml:"user"`
-	Password *string `json:"password" yaml:"password"`
-	Address  *string `json:"address" yaml:"address"`
+	User            *string `json:"user" yaml:"user"`
+	Password        *string `json:"password" yaml:"password"`
+	PasswordFile    *string `json:"passwordFile" yaml:"passwordFile"`
+	PasswordFromEnv *string `json:"passwordFromEnv" yaml:"passwordFromEnv"`
+	Address         *string `json:"address" yaml:"address"`
 }
 
 type NebulaClientSettings struct {
 	Retry             *int                    `json:"retry" yaml:"retry"`
 	Concurrency       *int                    `json:"concurrency" yaml:"concurrency"`
 	ChannelBufferSize *int                    `json:"channelBufferSize" yaml:"channelBufferSize"`
+	Timeout           *string                 `json:"timeout" yaml:"timeout"`
 	Space             *string                 `json:"space" yaml:"space"`
 	Connection        *NebulaClientConnection `json:"connection" yaml:"connection"`
 }
 
+// FileClientSettings override the client settings for a file, e.g. a small
+// file needs fewer connections than a huge one.
+type FileClientSettings struct {
+	Retry             *int    `json:"retry" yaml:"retry"`
+	Concurrency       *int    `json:"concurrency" yaml:"concurrency"`
+	ChannelBufferSize *int    `json:"channelBufferSize" yaml:"channelBufferSize"`
+	Timeout           *string `json:"timeout" yaml:"timeout"`
+}
+
 type Prop struct {
 	Name  *string `json:"name" yaml:"name"`
 	Type  *string `json:"type" yaml:"type"`
 	Index *int    `json:"index" yaml:"index"`
+	// Expr derives the value from the columns instead of Index
+	Expr *string `json:"expr" yaml:"expr"`
+	// Default replaces the null values if OnNull is default
+	Default *string `json:"default" yaml:"default"`
+	// NullValues are the cells which are null, [""] for all the types but
+	// string by default
+	NullValues []string `json:"nullValues" yaml:"nullValues"`
+	// OnNull is the action for the null values: default, skip-row or error
+	OnNull *string `json:"onNull" yaml:"onNull"`
+	// TrueValues and FalseValues are the literals of bool values
+	TrueValues  []string `json:"trueValues" yaml:"trueValues"`
+	FalseValues []string `json:"falseValues" yaml:"falseValues"`
+	// DecimalSeparator is the decimal separator of float and double values
+	DecimalSeparator *string `json:"decimalSeparator" yaml:"decimalSeparator"`
+	// Layouts are the layouts of timestamp values which aren't seconds, or
+	// the fallback layouts of date-timestamp values
+	Layouts []string `json:"layouts" yaml:"layouts"`
+	// TimeZone is the time zone of the times without zones, UTC by default
+	TimeZone  *string `json:"timeZone" yaml:"timeZone"`
+	location  *time.Location
+	expr      *expr.Expr
+	inferType bool
 }
 
 type VID struct {
 	Index    *int    `json:"index" yaml:"index"`
+	Expr     *string `json:"expr" yaml:"expr"`
 	Function *string `json:"function" yaml:"function"`
+	expr     *expr.Expr
 }
 
 type Rank struct {
-	Index *int `json:"index" yaml:"index"`
+	Index *int    `json:"index" yaml:"index"`
+	Expr  *string `json:"expr" yaml:"expr"`
+	expr  *expr.Expr
 }
 
 type Edge struct {
@@ -48,11 +89,48 @@ type Edge struct {
 	SrcVID      *VID    `json:"srcVID" yaml:"srcVID"`
 	DstVID      *VID    `json:"dstVID" yaml:"dstVID"`
 	Rank        *Rank   `json:"rank" yaml:"rank"`
+	// TypeColumn is the column of the edge type of each row, the edge types
+	// and their props are configured by Types instead of Name and Props
+	TypeColumn *TypeColumn `json:"typeColumn" yaml:"typeColumn"`
+	Types      []*EdgeType `json:"types" yaml:"types"`
+	// Explode splits a column into elements, and imports an edge of each
+	// element instead of the row
+	Explode *Explode `json:"explode" yaml:"explode"`
+}
+
+// Explode splits the column at Index by Delimiter, or as a JSON array if JSON
+// is true.
+type Explode struct {
+	Index     *int    `json:"index" yaml:"index"`
+	Delimiter *string `json:"delimiter" yaml:"delimiter"`
+	JSON      *bool   `json:"json" yaml:"json"`
+}
+
+type TypeColumn struct {
+	Index *int `json:"index" yaml:"index"`
+}
+
+// EdgeType is the edge type of the rows whose type column is Value.
+type EdgeType struct {
+	Name  *string `json:"name" yaml:"name"`
+	Value *string `json:"value" yaml:"value"`
+	Props []*Prop `json:"props" yaml:"props"`
 }
 
 type Tag struct {
 	Name  *string `json:"name" yaml:"name"`
 	Props []*Prop `json:"props" yaml:"props"`
+	// When is the condition of the rows the tag is inserted for, the tag is
+	// inserted for all the rows if it is nil
+	When *TagCondition `json:"when" yaml:"when"`
+}
+
+// TagCondition matches the rows whose column is one of Values, or is not empty
+// if NotEmpty is true.
+type TagCondition struct {
+	Index    *int     `json:"index" yaml:"index"`
+	Values   []string `json:"values" yaml:"values"`
+	NotEmpty *bool    `json:"notEmpty" yaml:"notEmpty"`
 }
 
 type Vertex struct {
@@ -61,52 +139,112 @@ type Vertex struct {
 }
 
 type Schema struct {
-	Type   *string `json:"type" yaml:"type"`
-	Edge   *Edge   `json:"edge" yaml:"edge"`
-	Vertex *Vertex `json:"vertex" yaml:"vertex"`
+	Template *string `json:"template" yaml:"template"`
+	Type     *string `json:"type" yaml:"type"`
+	Edge     *Edge   `json:"edge" yaml:"edge"`
+	Vertex   *Vertex `json:"vertex" yaml:"vertex"`
+	// FailDataPath is the file of the failed rows of this schema, only used
+	// by the schemas of files
+	FailDataPath *string `json:"failDataPath" yaml:"failDataPath"`
 }
 
 type CSVConfig struct {
-	WithHeader *bool   `json:"withHeader" yaml:"withHeader"`
-	WithLabel  *bool   `json:"withLabel" yaml:"withLabel"`
-	Delimiter  *string `json:"delimiter" yaml:"delimiter"`
+	Template      *string `json:"template" yaml:"template"`
+	WithHeader    *bool   `json:"withHeader" yaml:"withHeader"`
+	WithLabel     *bool   `json:"withLabel" yaml:"withLabel"`
+	SkipHeader    *bool   `json:"skipHeader" yaml:"skipHeader"`
+	Delimiter     *string `json:"delimiter" yaml:"delimiter"`
+	InferTypeRows *int    `json:"inferTypeRows" yaml:"inferTypeRows"`
 }
 
 type File struct {
-	Paths        []string
-	Path         *string    `json:"path" yaml:"path"`
-	FailDataPath *string    `json:"failDataPath" yaml:"failDataPath"`
-	BatchSize    *int       `json:"batchSize" yaml:"batchSize"`
-	Limit        *int       `json:"limit" yaml:"limit"`
-	InOrder      *bool      `json:"inOrder" yaml:"inOrder"`
-	Type         *string    `json:"type" yaml:"type"`
-	CSV          *CSVConfig `json:"csv" yaml:"csv"`
-	Schema       *Schema    `json:"schema" yaml:"schema"`
+	Paths          []string            `json:"-" yaml:"-"`
+	Name           *string             `json:"name" yaml:"name"`
+	Path           *string             `json:"path" yaml:"path"`
+	DependsOn      []string            `json:"dependsOn" yaml:"dependsOn"`
+	Stage          *int                `json:"stage" yaml:"stage"`
+	Space          *string             `json:"space" yaml:"space"`
+	ClientSettings *FileClientSettings `json:"clientSettings" yaml:"clientSettings"`
+	FailDataPath   *string             `json:"failDataPath" yaml:"failDataPath"`
+	BatchSize      *int                `json:"batchSize" yaml:"batchSize"`
+	Limit          *int                `json:"limit" yaml:"limit"`
+	InOrder        *bool               `json:"inOrder" yaml:"inOrder"`
+	// Filter is the expression of the rows to import, the others are skipped
+	Filter *string `json:"filter" yaml:"filter"`
+	// SkippedRowsPath is the file the rows skipped by Filter are written to
+	SkippedRowsPath *string    `json:"skippedRowsPath" yaml:"skippedRowsPath"`
+	Type            *string    `json:"type" yaml:"type"`
+	CSV             *CSVConfig `json:"csv" yaml:"csv"`
+	Schema          *Schema    `json:"schema" yaml:"schema"`
+	// Schemas are several vertex and edge schemas imported from each row
+	Schemas []*Schema `json:"schemas" yaml:"schemas"`
+	// Lookups are the tables used by lookup("name", key) in expressions
+	Lookups []*Lookup `json:"lookups" yaml:"lookups"`
+	// templateFields are the templates of the fields filled by them, by the
+	// paths of the fields like files[0].schema.edge.props
+	templateFields map[string]string
+	filter         *expr.Expr
+	// stage is the stage the file is imported in, after the stages of the
+	// files it depends on
+	stage int
+}
+
+// Templates are the named schema and csv settings which can be referred by
+// files, e.g. schema: {template: person}.
+type Templates struct {
+	Schemas map[string]*Schema    `json:"schemas" yaml:"schemas"`
+	CSV     map[string]*CSVConfig `json:"csv" yaml:"csv"`
 }
 
 type YAMLConfig struct {
 	Version              *string               `json:"version" yaml:"version"`
 	Description          *string               `json:"description" yaml:"description"`
+	Include              []string              `json:"include,omitempty" yaml:"include,omitempty"`
+	Templates            *Templates            `json:"templates" yaml:"templates"`
 	NebulaClientSettings *NebulaClientSettings `json:"clientSettings" yaml:"clientSettings"`
 	LogPath              *string               `json:"logPath" yaml:"logPath"`
 	Files                []*File               `json:"files" yaml:"files"`
 }
 
-var version string = "v1rc2"
+type ParseOptions struct {
+	// Overrides are applied in order after environment variables are substituted
+	Overrides []*Override
+	// AllowUnknownKeys ignores the keys which are not known by this version,
+	// instead of rejecting them as typos
+	AllowUnknownKeys bool
+}
 
 func Parse(filename string) (*YAMLConfig, error) {
-	content, err := ioutil.ReadFile(filename)
+	return ParseWithOptions(filename, ParseOptions{})
+}
+
+func ParseWithOptions(filename string, opts ParseOptions) (*YAMLConfig, error) {
+	lines := make(keyLines)
+	tree, err := loadTree(filename, nil, lines)
 	if err != nil {
 		return nil, err
 	}
+	if unknown := unknownKeys(tree, lines); len(unknown) > 0 && !opts.AllowUnknownKeys {
+		return nil, unknownKeysError(unknown)
+	}
 
-	var conf YAMLConfig
-	if err = yaml.Unmarshal(content, &conf); err != nil {
+	tree, warnings, err := migrateTree(tree)
+	if err != nil {
 		return nil, err
 	}
+	for _, w := range warnings {
+		logger.Warn(w)
+	}
+
+	for _, o := range opts.Overrides {
+		if tree, err = o.apply(tree); err != nil {
+			return nil, err
+		}
+	}
 
-	if conf.Version == nil && *conf.Version != version {
-		return nil, fmt.Errorf("The YAML configure version must be %s", version)
+	var conf YAMLConfig
+	if err = decodeTree(tree, &conf); err != nil {
+		return nil, err
 	}
 
 	path, err := filepath.Abs(filepath.Dir(filename))
@@ -114,18 +252,42 @@ func Parse(filename string) (*YAMLConfig, error) {
 		return nil, err
 	}
 	if err = conf.ValidateAndReset(path); err != nil {
-		return nil, err
+		return nil, annotate(err, opts.Overrides)
 	}
 
 	return &conf, nil
 }
 
 func (config *YAMLConfig) ValidateAndReset(dir string) error {
-	if config.NebulaClientSettings == nil {
-		return errors.New("please configure clientSettings")
+	if errs := config.validateAndReset(dir, false); len(errs) > 0 {
+		return errs[0]
 	}
-	if err := config.NebulaClientSettings.validateAndReset("clientSettings"); err != nil {
-		return err
+	return nil
+}
+
+// validateAndReset checks the configuration and resets the absent items to
+// their defaults. It stops at the first error unless all is true.
+func (config *YAMLConfig) validateAndReset(dir string, all bool) []error {
+	var errs []error
+	fail := func(err error) bool {
+		errs = append(errs, err)
+		return !all
+	}
+
+	if config.Version != nil && *config.Version != LatestVersion {
+		if fail(fmt.Errorf("The configure version must be %s, the older versions can be upgraded by the migrate command", LatestVersion)) {
+			return errs
+		}
+	}
+
+	if config.NebulaClientSettings == nil {
+		if fail(errors.New("please configure clientSettings")) {
+			return errs
+		}
+	} else if err := config.NebulaClientSettings.validateAndReset("clientSettings"); err != nil {
+		if fail(err) {
+			return errs
+		}
 	}
 
 	if config.LogPath == nil {
@@ -134,24 +296,159 @@ func (config *YAMLConfig) ValidateAndReset(dir string) error {
 		logger.Warnf("You have not configured the log file path in: logPath, reset to default path: %s", *config.LogPath)
 	}
 
+	if len(config.Include) > 0 {
+		if fail(errors.New("include is only supported in configuration files")) {
+			return errs
+		}
+	}
+
 	if config.Files == nil || len(config.Files) == 0 {
-		return errors.New("There is no files in configuration")
+		fail(errors.New("There is no files in configuration"))
+		return errs
 	}
 
 	for i := range config.Files {
-		if err := config.Files[i].validateAndReset(dir, fmt.Sprintf("files[%d]", i)); err != nil {
-			return err
+		prefix := fmt.Sprintf("files[%d]", i)
+		if config.Files[i] == nil {
+			if fail(fmt.Errorf("Please configure the file in: %s", prefix)) {
+				return errs
+			}
+			continue
 		}
+		if err := config.Files[i].applyTemplates(config.Templates, prefix); err != nil {
+			if fail(err) {
+				return errs
+			}
+			continue
+		}
+		if err := config.Files[i].validateAndReset(dir, prefix); err != nil {
+			if fail(err) {
+				return errs
+			}
+		} else if err := config.Files[i].validateAndResetLookups(dir, prefix); err != nil {
+			if fail(err) {
+				return errs
+			}
+		}
+		if err := config.Files[i].resetSpace(config.NebulaClientSettings, prefix); err != nil {
+			if fail(err) {
+				return errs
+			}
+		}
+	}
+
+	if err := config.resetStages(); err != nil {
+		fail(err)
 	}
 
+	return errs
+}
+
+// resetStages sets the stage of each file to be after the stages of the files
+// it depends on.
+func (config *YAMLConfig) resetStages() error {
+	byName := make(map[string]int)
+	for i, f := range config.Files {
+		if f == nil || f.Name == nil {
+			continue
+		}
+		if *f.Name == "" {
+			return fmt.Errorf("files[%d].name is empty string", i)
+		}
+		if j, ok := byName[*f.Name]; ok {
+			return fmt.Errorf("files[%d].name and files[%d].name are both %s", j, i, *f.Name)
+		}
+		byName[*f.Name] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(config.Files))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		f := config.Files[i]
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("Circular dependency between files: %s", strings.Join(append(path, *f.Name), " -> "))
+		}
+		states[i] = visiting
+		f.stage = 0
+		if f.Stage != nil {
+			if *f.Stage < 0 {
+				return fmt.Errorf("Invalid files[%d].stage: %d", i, *f.Stage)
+			}
+			f.stage = *f.Stage
+		}
+		for _, dep := range f.DependsOn {
+			j, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("files[%d].dependsOn refers to an unknown file: %s", i, dep)
+			}
+			name := fmt.Sprintf("files[%d]", i)
+			if f.Name != nil {
+				name = *f.Name
+			}
+			if err := visit(j, append(path, name)); err != nil {
+				return err
+			}
+			if s := config.Files[j].stage + 1; s > f.stage {
+				f.stage = s
+			}
+		}
+		states[i] = visited
+		return nil
+	}
+	for i, f := range config.Files {
+		if f == nil {
+			continue
+		}
+		if err := visit(i, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (n *NebulaClientSettings) validateAndReset(prefix string) error {
-	if n.Space == nil {
-		return fmt.Errorf("Please configure the space name in: %s.space", prefix)
+// Stages groups the files by the stage they are imported in, from the first
+// stage to the last. The files of a stage are imported concurrently, and a
+// stage starts after all the files of the previous stages are done.
+func (config *YAMLConfig) Stages() [][]*File {
+	byStage := make(map[int][]*File)
+	var stages []int
+	for _, f := range config.Files {
+		if f == nil {
+			continue
+		}
+		if _, ok := byStage[f.stage]; !ok {
+			stages = append(stages, f.stage)
+		}
+		byStage[f.stage] = append(byStage[f.stage], f)
+	}
+	sort.Ints(stages)
+	var files [][]*File
+	for _, s := range stages {
+		files = append(files, byStage[s])
+	}
+	return files
+}
+
+// String returns the name of the file, or its path if it has no name.
+func (f *File) String() string {
+	if f.Name != nil {
+		return *f.Name
+	}
+	if f.Path != nil {
+		return *f.Path
 	}
+	return ""
+}
 
+func (n *NebulaClientSettings) validateAndReset(prefix string) error {
 	if n.Retry == nil {
 		retry := 1
 		n.Retry = &retry
@@ -170,6 +467,10 @@ func (n *NebulaClientSettings) validateAndReset(prefix string) error {
 		logger.Warnf("Invalid client channel buffer size in %s.channelBufferSize, reset to %d", prefix, *n.ChannelBufferSize)
 	}
 
+	if err := checkTimeout(n.Timeout, prefix); err != nil {
+		return err
+	}
+
 	if n.Connection == nil {
 		return fmt.Errorf("Please configure the connection information in: %s.connection", prefix)
 	} else {
@@ -177,6 +478,65 @@ func (n *NebulaClientSettings) validateAndReset(prefix string) error {
 	}
 }
 
+// ForFile returns the client settings of the file, which are these settings
+// overridden by the clientSettings of the file.
+func (n *NebulaClientSettings) ForFile(f *File) *NebulaClientSettings {
+	settings := *n
+	if f == nil || f.ClientSettings == nil {
+		return &settings
+	}
+	o := f.ClientSettings
+	if o.Retry != nil {
+		settings.Retry = o.Retry
+	}
+	if o.Concurrency != nil {
+		settings.Concurrency = o.Concurrency
+	}
+	if o.ChannelBufferSize != nil {
+		settings.ChannelBufferSize = o.ChannelBufferSize
+	}
+	if o.Timeout != nil {
+		settings.Timeout = o.Timeout
+	}
+	return &settings
+}
+
+// TimeoutDuration returns the timeout of the connections, 0 if it is not
+// configured.
+func (n *NebulaClientSettings) TimeoutDuration() time.Duration {
+	if n.Timeout == nil {
+		return 0
+	}
+	d, _ := time.ParseDuration(*n.Timeout)
+	return d
+}
+
+func checkTimeout(timeout *string, prefix string) error {
+	if timeout == nil {
+		return nil
+	}
+	if d, err := time.ParseDuration(*timeout); err != nil || d <= 0 {
+		return fmt.Errorf("Invalid %s.timeout: %s, it should be a positive duration like 30s", prefix, *timeout)
+	}
+	return nil
+}
+
+func (c *FileClientSettings) validateAndReset(prefix string) error {
+	for _, v := range []struct {
+		name  string
+		value *int
+	}{
+		{"retry", c.Retry},
+		{"concurrency", c.Concurrency},
+		{"channelBufferSize", c.ChannelBufferSize},
+	} {
+		if v.value != nil && *v.value <= 0 {
+			return fmt.Errorf("Invalid %s.%s: %d", prefix, v.name, *v.value)
+		}
+	}
+	return checkTimeout(c.Timeout, prefix)
+}
+
 func (c *NebulaClientConnection) validateAndReset(prefix string) error {
 	if c.Address == nil {
 		a := "127.0.0.1:3699"
@@ -190,14 +550,55 @@ func (c *NebulaClientConnection) validateAndReset(prefix string) error {
 		logger.Warnf("%s.user: %s", prefix, *c.User)
 	}
 
+	numSources := 0
+	for _, s := range []*string{c.Password, c.PasswordFile, c.PasswordFromEnv} {
+		if s != nil {
+			numSources++
+		}
+	}
+	if numSources > 1 {
+		return fmt.Errorf("Only one of %s.password, %s.passwordFile and %s.passwordFromEnv can be configured", prefix, prefix, prefix)
+	}
+
+	if c.PasswordFile != nil {
+		b, err := ioutil.ReadFile(*c.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("Fail to read %s.passwordFile: %v", prefix, err)
+		}
+		p := strings.TrimRight(string(b), "\r\n")
+		c.Password = &p
+	}
+
+	if c.PasswordFromEnv != nil {
+		p, ok := os.LookupEnv(*c.PasswordFromEnv)
+		if !ok {
+			return fmt.Errorf("Environment variable %s in %s.passwordFromEnv is not set", *c.PasswordFromEnv, prefix)
+		}
+		c.Password = &p
+	}
+
 	if c.Password == nil {
 		p := "password"
 		c.Password = &p
-		logger.Warnf("%s.password: %s", prefix, *c.Password)
+		logger.Warnf("%s.password: %s", prefix, Redact(*c.Password))
 	}
 	return nil
 }
 
+// HasSecretReference reports whether the password is read from a file or an
+// environment variable of the importer host.
+func (c *NebulaClientConnection) HasSecretReference() bool {
+	return c.PasswordFile != nil || c.PasswordFromEnv != nil
+}
+
+// Redact hides a secret when it is logged or printed.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "******"
+}
+
 func (f *File) validateAndReset(dir, prefix string) error {
 	if f.Path == nil {
 		return fmt.Errorf("Please configure file path in: %s.path", prefix)
@@ -230,6 +631,14 @@ func (f *File) validateAndReset(dir, prefix string) error {
 		inOrder := false
 		f.InOrder = &inOrder
 	}
+	if f.ClientSettings != nil {
+		if err := f.ClientSettings.validateAndReset(fmt.Sprintf("%s.clientSettings", prefix)); err != nil {
+			return err
+		}
+	}
+	if f.Type == nil {
+		return fmt.Errorf("Please configure file type in: %s.type", prefix)
+	}
 	if strings.ToLower(*f.Type) != "csv" {
 		// TODO: Now only support csv import
 		return fmt.Errorf("Invalid file data type: %s, reset to csv", *f.Type)
@@ -238,14 +647,120 @@ func (f *File) validateAndReset(dir, prefix string) error {
 	if f.CSV != nil {
 		err := f.CSV.validateAndReset(fmt.Sprintf("%s.csv", prefix))
 		if err != nil {
+			return f.fromTemplate(err)
+		}
+	}
+
+	if f.Filter != nil {
+		x, err := expr.Compile(*f.Filter)
+		if err != nil {
+			return fmt.Errorf("%s.filter: %v", prefix, err)
+		}
+		if err := checkExprNames([]*expr.Expr{x}, f.withHeader(), fmt.Sprintf("%s.filter", prefix)); err != nil {
 			return err
 		}
+		f.filter = x
 	}
 
+	if f.Schema != nil && len(f.Schemas) > 0 {
+		return fmt.Errorf("Only one of %s.schema and %s.schemas can be configured", prefix, prefix)
+	}
+	if len(f.Schemas) > 0 {
+		return f.validateAndResetSchemas(prefix)
+	}
 	if f.Schema == nil {
 		return fmt.Errorf("Please configure file schema: %s.schema", prefix)
 	}
-	return f.Schema.validateAndReset(fmt.Sprintf("%s.schema", prefix))
+	if f.CSV != nil && f.CSV.IsInferType() {
+		f.Schema.inferUntypedProps()
+	}
+	if err := f.Schema.validateAndReset(fmt.Sprintf("%s.schema", prefix)); err != nil {
+		return f.fromTemplate(err)
+	}
+	return f.Schema.checkExprNames(f.withHeader(), fmt.Sprintf("%s.schema", prefix))
+}
+
+func (f *File) withHeader() bool {
+	return f.CSV != nil && f.CSV.WithHeader != nil && *f.CSV.WithHeader
+}
+
+func (f *File) validateAndResetSchemas(prefix string) error {
+	for i, s := range f.Schemas {
+		schemaPrefix := fmt.Sprintf("%s.schemas[%d]", prefix, i)
+		if s == nil {
+			return fmt.Errorf("Please configure the schema in: %s", schemaPrefix)
+		}
+		if f.CSV != nil && f.CSV.IsInferType() {
+			s.inferUntypedProps()
+		}
+		if err := s.validateAndReset(schemaPrefix); err != nil {
+			return f.fromTemplate(err)
+		}
+		if err := s.checkExprNames(f.withHeader(), schemaPrefix); err != nil {
+			return err
+		}
+		if s.FailDataPath == nil {
+			ext := filepath.Ext(*f.FailDataPath)
+			p := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(*f.FailDataPath, ext), i, ext)
+			s.FailDataPath = &p
+			logger.Infof("%s.failDataPath: %s", schemaPrefix, p)
+		}
+	}
+	return nil
+}
+
+// Mappings returns the schemas the rows of the file are imported as.
+func (f *File) Mappings() []*Schema {
+	if len(f.Schemas) > 0 {
+		return f.Schemas
+	}
+	return []*Schema{f.Schema}
+}
+
+// MappingName returns the name of the schema in the file, schemas[i], or an
+// empty string if the file has only one schema.
+func (f *File) MappingName(s *Schema) string {
+	for i, m := range f.Schemas {
+		if m == s && len(f.Schemas) > 1 {
+			return fmt.Sprintf("schemas[%d]", i)
+		}
+	}
+	return ""
+}
+
+// MappingFailDataPath returns the file of the failed rows of the schema.
+func (f *File) MappingFailDataPath(s *Schema) string {
+	if s.FailDataPath != nil {
+		return *s.FailDataPath
+	}
+	return *f.FailDataPath
+}
+
+// NumMappings returns the number of the schemas of all the files.
+func (config *YAMLConfig) NumMappings() int {
+	n := 0
+	for _, f := range config.Files {
+		if f != nil {
+			n += len(f.Mappings())
+		}
+	}
+	return n
+}
+
+// resetSpace sets the space of the file to the one of clientSettings if it is
+// not configured, so every file knows which space its data goes to.
+func (f *File) resetSpace(settings *NebulaClientSettings, prefix string) error {
+	if f.Space != nil {
+		if strings.TrimSpace(*f.Space) == "" {
+			return fmt.Errorf("%s.space is empty string", prefix)
+		}
+		return nil
+	}
+	if settings == nil || settings.Space == nil {
+		return fmt.Errorf("Please configure the space name in: %s.space or clientSettings.space", prefix)
+	}
+	f.Space = settings.Space
+	return nil
 }
 
 func (c *CSVConfig) validateAndReset(prefix string) error {
@@ -261,15 +776,57 @@ func (c *CSVConfig) validateAndReset(prefix string) error {
 		logger.Infof("%s.withLabel: %v", prefix, false)
 	}
 
+	if c.IsSkipHeader() && !*c.WithHeader {
+		return fmt.Errorf("%s.skipHeader requires %s.withHeader", prefix, prefix)
+	}
+
 	if c.Delimiter != nil {
 		if len(*c.Delimiter) == 0 {
 			return fmt.Errorf("%s.delimiter is empty string", prefix)
 		}
 	}
 
+	if c.InferTypeRows != nil && *c.InferTypeRows < 0 {
+		return fmt.Errorf("Invalid %s.inferTypeRows: %d", prefix, *c.InferTypeRows)
+	}
+
 	return nil
 }
 
+// IsSkipHeader reports whether the header only names the columns, and is
+// skipped instead of defining the schema.
+func (c *CSVConfig) IsSkipHeader() bool {
+	return c.SkipHeader != nil && *c.SkipHeader
+}
+
+// IsInferType reports whether the types of props are inferred from the rows
+// when they are not configured.
+func (c *CSVConfig) IsInferType() bool {
+	return c.InferTypeRows != nil && *c.InferTypeRows > 0
+}
+
+// inferUntypedProps marks the props without types to be inferred.
+func (s *Schema) inferUntypedProps() {
+	var props []*Prop
+	if s.Edge != nil {
+		props = append(props, s.Edge.Props...)
+	}
+	// The conditional tags are only configured in the schema, they can't
+	// be inferred as the header
+	if s.Vertex != nil && !s.Vertex.HasConditions() {
+		for _, t := range s.Vertex.Tags {
+			if t != nil {
+				props = append(props, t.Props...)
+			}
+		}
+	}
+	for _, p := range props {
+		if p != nil && p.Type == nil {
+			p.inferType = true
+		}
+	}
+}
+
 func (s *Schema) IsVertex() bool {
 	return strings.ToUpper(*s.Type) == "VERTEX"
 }
@@ -283,6 +840,9 @@ func (s *Schema) String() string {
 }
 
 func (s *Schema) validateAndReset(prefix string) error {
+	if s.Type == nil {
+		return fmt.Errorf("Please configure schema type in: %s.type", prefix)
+	}
 	var err error = nil
 	switch strings.ToLower(*s.Type) {
 	case "edge":
@@ -336,6 +896,17 @@ func (v *VID) checkFunction(prefix string) error {
 }
 
 func (v *VID) validateAndReset(prefix string, defaultVal int) error {
+	if v.Expr != nil {
+		if v.Index != nil {
+			return fmt.Errorf("Only one of %s.index and %s.expr can be configured", prefix, prefix)
+		}
+		x, err := compileExpr(*v.Expr, prefix)
+		if err != nil {
+			return err
+		}
+		v.expr = x
+		return v.checkFunction(prefix)
+	}
 	if v.Index == nil {
 		v.Index = &defaultVal
 	}
@@ -349,6 +920,14 @@ func (v *VID) validateAndReset(prefix string, defaultVal int) error {
 }
 
 func (r *Rank) validateAndReset(prefix string, defaultVal int) error {
+	if r.Expr != nil {
+		if r.Index != nil {
+			return fmt.Errorf("Only one of %s.index and %s.expr can be configured", prefix, prefix)
+		}
+		x, err := compileExpr(*r.Expr, prefix)
+		r.expr = x
+		return err
+	}
 	if r.Index == nil {
 		r.Index = &defaultVal
 	}
@@ -358,33 +937,32 @@ func (r *Rank) validateAndReset(prefix string, defaultVal int) error {
 	return nil
 }
 
-func (e *Edge) FormatValues(record base.Record) string {
+func (e *Edge) FormatValues(record base.Record, env *expr.Env) (string, error) {
 	var cells []string
 	for i, prop := range e.Props {
-		if c, err := prop.FormatValue(record); err != nil {
-			logger.Fatalf("edge: %s, column: %d, error: %v", e.String(), i, err)
-		} else {
-			cells = append(cells, c)
+		c, err := prop.FormatValue(record, env)
+		if err != nil {
+			return "", fmt.Errorf("edge: %s, prop: %d, error: %w", *e.Name, i, err)
 		}
+		cells = append(cells, c)
 	}
 	rank := ""
-	if e.Rank != nil && e.Rank.Index != nil {
-		rank = fmt.Sprintf("@%s", record[*e.Rank.Index])
+	if e.Rank != nil && (e.Rank.Index != nil || e.Rank.expr != nil) {
+		r, err := cellValue(record, e.Rank.Index, e.Rank.expr, env)
+		if err != nil {
+			return "", fmt.Errorf("edge: %s, rank error: %w", *e.Name, err)
+		}
+		rank = fmt.Sprintf("@%s", r)
 	}
-	var srcVID string
-	if e.SrcVID.Function != nil {
-		//TODO(yee): differentiate string and integer column type, find and compare src/dst vertex column with property
-		srcVID = fmt.Sprintf("%s(%q)", *e.SrcVID.Function, record[*e.SrcVID.Index])
-	} else {
-		srcVID = base.TryConvInt64(record[*e.SrcVID.Index])
+	srcVID, err := e.SrcVID.FormatValue(record, env)
+	if err != nil {
+		return "", fmt.Errorf("edge: %s, srcVID error: %w", *e.Name, err)
 	}
-	var dstVID string
-	if e.DstVID.Function != nil {
-		dstVID = fmt.Sprintf("%s(%q)", *e.DstVID.Function, record[*e.DstVID.Index])
-	} else {
-		dstVID = base.TryConvInt64(record[*e.DstVID.Index])
+	dstVID, err := e.DstVID.FormatValue(record, env)
+	if err != nil {
+		return "", fmt.Errorf("edge: %s, dstVID error: %w", *e.Name, err)
 	}
-	return fmt.Sprintf(" %s->%s%s:(%s) ", srcVID, dstVID, rank, strings.Join(cells, ","))
+	return fmt.Sprintf(" %s->%s%s:(%s) ", srcVID, dstVID, rank, strings.Join(cells, ",")), nil
 }
 
 func (e *Edge) maxIndex() int {
@@ -401,7 +979,17 @@ func (e *Edge) maxIndex() int {
 		maxIdx = *e.Rank.Index
 	}
 
-	for _, p := range e.Props {
+	props := e.Props
+	if e.HasTypes() {
+		if e.TypeColumn.Index != nil && *e.TypeColumn.Index > maxIdx {
+			maxIdx = *e.TypeColumn.Index
+		}
+		for _, t := range e.Types {
+			props = append(props, t.Props...)
+		}
+	}
+
+	for _, p := range props {
 		if p != nil && p.Index != nil && *p.Index > maxIdx {
 			maxIdx = *p.Index
 		}
@@ -410,6 +998,63 @@ func (e *Edge) maxIndex() int {
 	return maxIdx
 }
 
+// HasTypes reports whether the edge type of each row is chosen by the type
+// column.
+func (e *Edge) HasTypes() bool {
+	return e.TypeColumn != nil && len(e.Types) > 0
+}
+
+// TypedEdges returns the edges of the types by the values of the type column,
+// which share the VIDs and the rank of e.
+func (e *Edge) TypedEdges() map[string]*Edge {
+	edges := make(map[string]*Edge, len(e.Types))
+	for _, t := range e.Types {
+		edges[*t.Value] = &Edge{
+			Name:   t.Name,
+			Props:  t.Props,
+			SrcVID: e.SrcVID,
+			DstVID: e.DstVID,
+			Rank:   e.Rank,
+		}
+	}
+	return edges
+}
+
+func (e *Edge) validateAndResetTypes(prefix string, start int) error {
+	if e.Name != nil || len(e.Props) > 0 {
+		return fmt.Errorf("%s.name and %s.props can't be configured with %s.types", prefix, prefix, prefix)
+	}
+	if e.TypeColumn == nil || e.TypeColumn.Index == nil {
+		return fmt.Errorf("Please configure the column of edge types in: %s.typeColumn.index", prefix)
+	}
+	if *e.TypeColumn.Index < 0 {
+		return fmt.Errorf("Invalid %s.typeColumn.index: %d", prefix, *e.TypeColumn.Index)
+	}
+	values := make(map[string]bool)
+	for i, t := range e.Types {
+		typePrefix := fmt.Sprintf("%s.types[%d]", prefix, i)
+		if t == nil || t.Name == nil {
+			return fmt.Errorf("Please configure the edge name in: %s.name", typePrefix)
+		}
+		if t.Value == nil {
+			t.Value = t.Name
+		}
+		if values[*t.Value] {
+			return fmt.Errorf("Duplicate value %s of %s.value", *t.Value, typePrefix)
+		}
+		values[*t.Value] = true
+		for j := range t.Props {
+			if t.Props[j] == nil {
+				return fmt.Errorf("Please configure the prop in: %s.props[%d]", typePrefix, j)
+			}
+			if err := t.Props[j].validateAndReset(fmt.Sprintf("%s.props[%d]", typePrefix, j), j+start); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func combine(cell, val string) string {
 	if len(cell) > 0 {
 		return fmt.Sprintf("%s/%s", cell, val)
@@ -429,6 +1074,18 @@ func (e *Edge) String() string {
 	if e.Rank != nil && e.Rank.Index != nil {
 		cells[*e.Rank.Index] = combine(cells[*e.Rank.Index], base.LABEL_RANK)
 	}
+	if e.HasTypes() {
+		if e.TypeColumn.Index != nil {
+			cells[*e.TypeColumn.Index] = combine(cells[*e.TypeColumn.Index], base.LABEL_TYPE)
+		}
+		for _, t := range e.Types {
+			for _, prop := range t.Props {
+				if prop != nil && prop.Index != nil {
+					cells[*prop.Index] = combine(cells[*prop.Index], prop.String(*t.Name))
+				}
+			}
+		}
+	}
 	for _, prop := range e.Props {
 		if prop.Index != nil {
 			cells[*prop.Index] = combine(cells[*prop.Index], prop.String(*e.Name))
@@ -439,11 +1096,37 @@ func (e *Edge) String() string {
 			cells[i] = base.LABEL_IGNORE
 		}
 	}
+	// The derived values are appended after the columns
+	if e.SrcVID != nil && e.SrcVID.expr != nil {
+		cells = append(cells, fmt.Sprintf("%s=%s", e.SrcVID.String(base.LABEL_SRC_VID), e.SrcVID.expr))
+	}
+	if e.DstVID != nil && e.DstVID.expr != nil {
+		cells = append(cells, fmt.Sprintf("%s=%s", e.DstVID.String(base.LABEL_DST_VID), e.DstVID.expr))
+	}
+	if e.Rank != nil && e.Rank.expr != nil {
+		cells = append(cells, fmt.Sprintf("%s=%s", base.LABEL_RANK, e.Rank.expr))
+	}
+	if e.Name != nil {
+		cells = append(cells, exprCells(e.Props, *e.Name)...)
+	}
+	for _, t := range e.Types {
+		cells = append(cells, exprCells(t.Props, *t.Name)...)
+	}
 	return strings.Join(cells, ",")
 }
 
+func exprCells(props []*Prop, prefix string) []string {
+	var cells []string
+	for _, p := range props {
+		if p != nil && p.expr != nil {
+			cells = append(cells, fmt.Sprintf("%s=%s", p.String(prefix), p.expr))
+		}
+	}
+	return cells
+}
+
 func (e *Edge) validateAndReset(prefix string) error {
-	if e.Name == nil {
+	if e.Name == nil && len(e.Types) == 0 {
 		return fmt.Errorf("Please configure edge name in: %s.name", prefix)
 	}
 	if e.SrcVID != nil {
@@ -475,9 +1158,17 @@ func (e *Edge) validateAndReset(prefix string) error {
 			start++
 		}
 	}
+	if e.Explode != nil {
+		if err := e.Explode.validateAndReset(fmt.Sprintf("%s.explode", prefix)); err != nil {
+			return err
+		}
+	}
+	if len(e.Types) > 0 {
+		return e.validateAndResetTypes(prefix, start)
+	}
 	for i := range e.Props {
 		if e.Props[i] != nil {
-			if err := e.Props[i].validateAndReset(fmt.Sprintf("%s.prop[%d]", prefix, i), i+start); err != nil {
+			if err := e.Props[i].validateAndReset(fmt.Sprintf("%s.props[%d]", prefix, i), i+start); err != nil {
 				return err
 			}
 		} else {
@@ -487,18 +1178,93 @@ func (e *Edge) validateAndReset(prefix string) error {
 	return nil
 }
 
-func (v *Vertex) FormatValues(record base.Record) string {
+// IsJSON reports whether the column is exploded as a JSON array.
+func (x *Explode) IsJSON() bool {
+	return x.JSON != nil && *x.JSON
+}
+
+func (x *Explode) validateAndReset(prefix string) error {
+	if x.Index == nil {
+		return fmt.Errorf("Please configure the column to explode in: %s.index", prefix)
+	}
+	if *x.Index < 0 {
+		return fmt.Errorf("Invalid %s.index: %d", prefix, *x.Index)
+	}
+	if x.IsJSON() {
+		if x.Delimiter != nil {
+			return fmt.Errorf("Only one of %s.delimiter and %s.json can be configured", prefix, prefix)
+		}
+		return nil
+	}
+	if x.Delimiter == nil || *x.Delimiter == "" {
+		return fmt.Errorf("Please configure %s.delimiter, or %s.json for the JSON arrays", prefix, prefix)
+	}
+	return nil
+}
+
+func (v *Vertex) FormatValues(record base.Record, env *expr.Env) (string, error) {
 	var cells []string
 	for _, tag := range v.Tags {
-		cells = append(cells, tag.FormatValues(record))
+		c, err := tag.FormatValues(record, env)
+		if err != nil {
+			return "", err
+		}
+		cells = append(cells, c)
 	}
-	var vid string
-	if v.VID.Function != nil {
-		vid = fmt.Sprintf("%s(%q)", *v.VID.Function, record[*v.VID.Index])
-	} else {
-		vid = base.TryConvInt64(record[*v.VID.Index])
+	vid, err := v.VID.FormatValue(record, env)
+	if err != nil {
+		return "", fmt.Errorf("vid error: %w", err)
+	}
+	return fmt.Sprintf(" %s: (%s)", vid, strings.Join(cells, ",")), nil
+}
+
+// HasConditions reports whether some tags are only inserted for the rows
+// matching their conditions.
+func (v *Vertex) HasConditions() bool {
+	for _, t := range v.Tags {
+		if t != nil && t.When != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTags returns the indexes of the tags inserted for the record.
+func (v *Vertex) MatchTags(record base.Record) []int {
+	var tags []int
+	for i, t := range v.Tags {
+		if t != nil && (t.When == nil || t.When.Match(record)) {
+			tags = append(tags, i)
+		}
+	}
+	return tags
+}
+
+func (c *TagCondition) Match(record base.Record) bool {
+	if *c.Index >= len(record) {
+		return false
 	}
-	return fmt.Sprintf(" %s: (%s)", vid, strings.Join(cells, ","))
+	cell := record[*c.Index]
+	if c.NotEmpty != nil && *c.NotEmpty {
+		return strings.TrimSpace(cell) != ""
+	}
+	for _, v := range c.Values {
+		if cell == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TagCondition) validateAndReset(prefix string) error {
+	if c.Index == nil || *c.Index < 0 {
+		return fmt.Errorf("Please configure the column index in: %s.index", prefix)
+	}
+	notEmpty := c.NotEmpty != nil && *c.NotEmpty
+	if notEmpty == (len(c.Values) > 0) {
+		return fmt.Errorf("Please configure one of %s.values and %s.notEmpty", prefix, prefix)
+	}
+	return nil
 }
 
 func (v *Vertex) maxIndex() int {
@@ -537,6 +1303,13 @@ func (v *Vertex) String() string {
 			cells[i] = base.LABEL_IGNORE
 		}
 	}
+	// The derived values are appended after the columns
+	if v.VID != nil && v.VID.expr != nil {
+		cells = append(cells, fmt.Sprintf("%s=%s", v.VID.String(base.LABEL_VID), v.VID.expr))
+	}
+	for _, tag := range v.Tags {
+		cells = append(cells, exprCells(tag.Props, *tag.Name)...)
+	}
 	return strings.Join(cells, ",")
 }
 
@@ -578,52 +1351,84 @@ func (p *Prop) IsDateTimestampType() bool {
 	return strings.HasPrefix(strings.ToLower(*p.Type), "date-timestamp")
 }
 
-func (p *Prop) FormatValue(record base.Record) (string, error) {
-	if p.Index != nil && *p.Index >= len(record) {
-		return "", fmt.Errorf("Prop index %d out range %d of record(%v)", *p.Index, len(record), record)
+func (p *Prop) FormatValue(record base.Record, env *expr.Env) (string, error) {
+	r, err := cellValue(record, p.Index, p.expr, env)
+	if err != nil {
+		return "", fmt.Errorf("prop %s: %w", *p.Name, err)
+	}
+	if r, err = p.replaceNull(r); err != nil {
+		return "", err
 	}
-	r := record[*p.Index]
 	if p.IsStringType() {
-		return fmt.Sprintf("%q", r), nil
+		return base.QuoteNGQL(r), nil
 	}
 	if p.IsIntType() {
 		return base.TryConvInt64(r), nil
 	}
-	if p.IsDateTimestampType() {
-		return base.TryConvDateTimestamp(r, strings.Split(*p.Type, ":")[1]), nil
-	}
-	return r, nil
+	return p.parseValue(r)
 }
 
 func (p *Prop) String(prefix string) string {
+	// The type is inferred when the schema is initialized
+	if p.Type == nil {
+		return fmt.Sprintf("%s.%s", prefix, *p.Name)
+	}
 	return fmt.Sprintf("%s.%s:%s", prefix, *p.Name, *p.Type)
 }
 
 func (p *Prop) validateAndReset(prefix string, val int) error {
-	*p.Type = strings.ToLower(*p.Type)
-	if !base.IsValidType(*p.Type) {
-		return fmt.Errorf("Error property type of %s.type: %s", prefix, *p.Type)
+	if p.Expr != nil {
+		if p.Index != nil {
+			return fmt.Errorf("Only one of %s.index and %s.expr can be configured", prefix, prefix)
+		}
+		x, err := compileExpr(*p.Expr, prefix)
+		if err != nil {
+			return err
+		}
+		p.expr = x
+		// The types of derived values can't be inferred
+		p.inferType = false
+	}
+	if p.Type == nil {
+		if !p.inferType {
+			t := "string"
+			p.Type = &t
+			logger.Warnf("You have not configured the type of %s.type, reset to %s", prefix, *p.Type)
+		}
+	} else {
+		// The layout of date-timestamp is case-sensitive, e.g. 02-Jan-2006
+		parts := strings.SplitN(*p.Type, ":", 2)
+		parts[0] = strings.ToLower(parts[0])
+		*p.Type = strings.Join(parts, ":")
+		if !base.IsValidType(*p.Type) {
+			return fmt.Errorf("Error property type of %s.type: %s", prefix, *p.Type)
+		}
 	}
 	if p.Index == nil {
-		p.Index = &val
+		if p.expr == nil {
+			p.Index = &val
+		}
 	} else {
 		if *p.Index < 0 {
-			logger.Fatalf("Invalid prop index: %d, name: %s, type: %s", *p.Index, *p.Name, *p.Type)
+			return fmt.Errorf("Invalid %s.index: %d", prefix, *p.Index)
 		}
 	}
-	return nil
+	if err := p.validateParsing(prefix); err != nil {
+		return err
+	}
+	return p.validateNull(prefix)
 }
 
-func (t *Tag) FormatValues(record base.Record) string {
+func (t *Tag) FormatValues(record base.Record, env *expr.Env) (string, error) {
 	var cells []string
 	for _, p := range t.Props {
-		if c, err := p.FormatValue(record); err != nil {
-			logger.Fatalf("tag: %v, error: %v", *t, err)
-		} else {
-			cells = append(cells, c)
+		c, err := p.FormatValue(record, env)
+		if err != nil {
+			return "", fmt.Errorf("tag: %s, error: %w", *t.Name, err)
 		}
+		cells = append(cells, c)
 	}
-	return strings.Join(cells, ",")
+	return strings.Join(cells, ","), nil
 }
 
 func (t *Tag) validateAndReset(prefix string, start int) error {
@@ -631,6 +1436,12 @@ func (t *Tag) validateAndReset(prefix string, start int) error {
 		return fmt.Errorf("Please configure the vertex tag name in: %s.name", prefix)
 	}
 
+	if t.When != nil {
+		if err := t.When.validateAndReset(fmt.Sprintf("%s.when", prefix)); err != nil {
+			return err
+		}
+	}
+
 	for i := range t.Props {
 		if t.Props[i] != nil {
 			if err := t.Props[i].validateAndReset(fmt.Sprintf("%s.props[%d]", prefix, i), i+start); err != nil {
@@ -1,10 +1,20 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -105,3 +115,851 @@ func TestJsonTypeEmbeding(t *testing.T) {
 	b, _ := json.Marshal(man)
 	t.Logf("%s", string(b))
 }
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("NEBULA_TEST_SPACE", "prod")
+	os.Setenv("NEBULA_TEST_CONCURRENCY", "4")
+	os.Setenv("NEBULA_TEST_EMPTY", "")
+	defer os.Unsetenv("NEBULA_TEST_SPACE")
+	defer os.Unsetenv("NEBULA_TEST_CONCURRENCY")
+	defer os.Unsetenv("NEBULA_TEST_EMPTY")
+
+	cases := []struct {
+		in  string
+		out interface{}
+	}{
+		{"${NEBULA_TEST_SPACE}", "prod"},
+		{"space_${NEBULA_TEST_SPACE}", "space_prod"},
+		{"${NEBULA_TEST_CONCURRENCY}", 4},
+		{"v${NEBULA_TEST_CONCURRENCY}", "v4"},
+		{"${NEBULA_TEST_EMPTY:-default}", "default"},
+		{"${NEBULA_TEST_UNSET:-127.0.0.1:3699}", "127.0.0.1:3699"},
+		{"$${NEBULA_TEST_SPACE}", "${NEBULA_TEST_SPACE}"},
+		{"pa$word", "pa$word"},
+	}
+	for _, c := range cases {
+		out, err := expandEnv("test", c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != c.out {
+			t.Errorf("expand %s: expect %v, actual %v", c.in, c.out, out)
+		}
+	}
+
+	for _, in := range []string{"${NEBULA_TEST_UNSET}", "${NEBULA_TEST_UNSET:?password is required}"} {
+		if _, err := expandEnv("test", in); err == nil {
+			t.Errorf("expand %s should fail", in)
+		}
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	env, err := EnvOverrides([]string{"NEBULA_IMPORTER__FILES__1__BATCHSIZE=7", "PATH=/bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseOverride("clientSettings.space=prod", "--set clientSettings.space=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ParseWithOptions("../../examples/example.yaml", ParseOptions{Overrides: append(env, set)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *conf.NebulaClientSettings.Space != "prod" {
+		t.Errorf("Error space: %s", *conf.NebulaClientSettings.Space)
+	}
+	if *conf.Files[1].BatchSize != 7 {
+		t.Errorf("Error batch size: %d", *conf.Files[1].BatchSize)
+	}
+
+	for _, s := range []string{"clientSettings.spaces=prod", "files[0].batchSize=many", "files.name=x", "space"} {
+		if _, err := ParseOverride(s, s); err == nil {
+			t.Errorf("Override %s should fail", s)
+		}
+	}
+
+	typ, err := ParseOverride("files[0].schema.type=node", "--set files[0].schema.type=node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseWithOptions("../../examples/example.yaml", ParseOptions{Overrides: []*Override{typ}})
+	if err == nil || !strings.Contains(err.Error(), "is set by --set files[0].schema.type=node") {
+		t.Errorf("Error should point at the override: %v", err)
+	}
+
+	batch, err := ParseOverride("files[1].batchSize=7", "--set files[1].batchSize=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for msg, annotated := range map[string]bool{
+		"files[10].batchSize must be positive":                     false,
+		"files[1].batchSize must be positive":                      true,
+		"files[10].path is empty, files[1].batchSize is too large": true,
+		"Invalid type of files[1].batchSize: not an int":           true,
+	} {
+		err := annotate(errors.New(msg), []*Override{batch})
+		if got := strings.Contains(err.Error(), "is set by"); got != annotated {
+			t.Errorf("Error annotation of %q: %v", msg, err)
+		}
+	}
+}
+
+// tempDir creates a temporary directory, which is removed by the returned
+// function.
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// testdata returns the content of the configure file in testdata.
+func testdata(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// writeConfig writes the configure and the files by their names into the
+// directory, and returns the path of the configure file.
+func writeConfig(t *testing.T, dir string, conf string, files map[string]string) string {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// invalidCase is the configure with old replaced by new, which fails to be
+// parsed with err.
+type invalidCase struct {
+	old string
+	new string
+	err string
+}
+
+func checkInvalid(t *testing.T, dir string, conf string, cases []invalidCase) {
+	for _, c := range cases {
+		path := writeConfig(t, dir, strings.Replace(conf, c.old, c.new, 1), nil)
+		if _, err := Parse(path); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Error of replacing %q by %q should contain %q: %v", c.old, c.new, c.err, err)
+		}
+	}
+}
+
+const followCSV = "200,201,0,92.5\n"
+
+func TestIncludeAndTemplates(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	files := map[string]string{"base.yaml": testdata(t, "base.yaml"), "follow.csv": followCSV}
+	conf, err := Parse(writeConfig(t, dir, testdata(t, "main.yaml"), files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Files) != 2 {
+		t.Fatalf("Included files should be concatenated, actual %d files", len(conf.Files))
+	}
+	edge := conf.Files[1].Schema.Edge
+	if *edge.Name != "like" || *edge.WithRanking || len(edge.Props) != 1 || *edge.Props[0].Name != "likeness" {
+		t.Errorf("Error edge from template: %s", edge.String())
+	}
+	if *conf.Files[0].Schema.Edge.Name != "follow" || conf.Files[0].Schema.Edge.Rank == nil {
+		t.Errorf("Template should not be changed by other files: %s", conf.Files[0].Schema.Edge.String())
+	}
+	if *conf.Files[1].CSV.WithHeader {
+		t.Error("Error csv from template")
+	}
+
+	path := writeConfig(t, dir, strings.Replace(testdata(t, "base.yaml"), "type: double", "type: decimal", 1), nil)
+	if _, err := Parse(path); err == nil || !strings.Contains(err.Error(), "(from template follow)") {
+		t.Errorf("Error should tell the template: %v", err)
+	}
+	files["base.yaml"] = strings.Replace(testdata(t, "base.yaml"), "      withLabel: false\n", "      withLabel: false\n      withLabels: false\n", 1)
+	files["base.yaml"] = strings.Replace(files["base.yaml"], "    type: csv\n", "    type: csv\n    batchSize: many\n", 1)
+	path = writeConfig(t, dir, testdata(t, "main.yaml"), files)
+	_, errs := Validate(path, ParseOptions{})
+	basePath := filepath.Join(dir, "base.yaml")
+	for _, e := range []string{
+		"Unknown key: templates.csv.plain.withLabels (" + basePath + " line 11)",
+		"Invalid type of files[0].batchSize: cannot unmarshal !!str `many` into int (" + basePath + " line 24)",
+	} {
+		found := false
+		for _, err := range errs {
+			found = found || strings.Contains(err.Error(), e)
+		}
+		if !found {
+			t.Errorf("Problem %q of the included file is not reported in %v", e, errs)
+		}
+	}
+
+	props := "withRanking: false\n        props:\n          - name: likeness\n            type: decimal"
+	path = writeConfig(t, dir, strings.Replace(testdata(t, "main.yaml"), "withRanking: false", props, 1), nil)
+	if _, err := Parse(path); err == nil || strings.Contains(err.Error(), "from template") {
+		t.Errorf("Error of the field not from the template should not tell the template: %v", err)
+	}
+}
+
+func TestIncludePaths(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	common := filepath.Join(dir, "common")
+	if err := os.Mkdir(common, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, common, testdata(t, "base.yaml"), map[string]string{"follow.csv": followCSV})
+	conf := strings.Replace(testdata(t, "main.yaml"), "./base.yaml", "./common/config.yaml", 1)
+	parsed, err := Parse(writeConfig(t, dir, conf, map[string]string{"follow.csv": followCSV}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed.Files[0].Path != filepath.Join(common, "follow.csv") {
+		t.Errorf("The path of the included file should be relative to it: %s", *parsed.Files[0].Path)
+	}
+	if *parsed.Files[1].Path != filepath.Join(dir, "follow.csv") {
+		t.Errorf("The path of the main file should be relative to it: %s", *parsed.Files[1].Path)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	path := writeConfig(t, dir, testdata(t, "v1rc1.yaml"), map[string]string{"follow.csv": followCSV})
+	content, warnings, err := MigrateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) == 0 {
+		t.Error("Migration should warn")
+	}
+	for _, s := range []string{"version: v1rc2", "index: 2", "index: 3"} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("Migrated configure should contain %q:\n%s", s, content)
+		}
+	}
+	if strings.Contains(string(content), "withRanking") {
+		t.Errorf("withRanking should be migrated:\n%s", content)
+	}
+
+	conf, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := conf.Files[0].Schema.Edge
+	if *conf.Version != LatestVersion || edge.Rank == nil || *edge.Rank.Index != 2 || *edge.Props[0].Index != 3 {
+		t.Errorf("Error migrated edge: %s", edge.String())
+	}
+
+	checkInvalid(t, dir, testdata(t, "v1rc1.yaml"), []invalidCase{
+		{"version: v1rc1", "version: v0", "supported versions are: v1rc1, v1rc2"},
+		{"version: v1rc1", "", "supported versions are: v1rc1, v1rc2"},
+	})
+}
+
+func TestValidate(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	path := writeConfig(t, dir, testdata(t, "invalid.yaml"), map[string]string{"follow.csv": "200,201,92.5\n"})
+	conf, errs := Validate(path, ParseOptions{})
+	if conf == nil {
+		t.Fatal("The configure should be decoded")
+	}
+	expected := []string{
+		"Unknown key: files[1].batchsize, did you mean batchSize?",
+		"Invalid type of clientSettings.concurrency: cannot unmarshal !!str `many` into int (" + path + " line 4)",
+		"File(./missing.csv) doesn't exist",
+		"Error property type of files[1].schema.edge.props[0].type: decimal",
+		"files[1].schema.edge.props[0], files[1].schema.edge.props[1] are mapped to the same column 5",
+		"files[1].schema.edge.props[1].index 5 is out of range",
+	}
+	for _, e := range expected {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), e) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Problem %q is not reported in %v", e, errs)
+		}
+	}
+
+	content, err := conf.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "password: '******'") {
+		t.Errorf("Password should be redacted:\n%s", content)
+	}
+}
+
+func TestDump(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(testdata(t, "expr.yaml"), "            index: 7\n", "            index: 7\n            nullValues: []\n", 1)
+	conf, err := Parse(writeConfig(t, dir, content, map[string]string{"orders.csv": ordersCSV}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := conf.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(dump), "[]") != 1 || !strings.Contains(string(dump), "nullValues: []") {
+		t.Errorf("Only the empty list which is set should be dumped:\n%s", dump)
+	}
+	if strings.Contains(string(dump), "templates") {
+		t.Errorf("The empty items should not be dumped:\n%s", dump)
+	}
+	conf, err = Parse(writeConfig(t, dir, string(dump), nil))
+	if err != nil {
+		t.Fatalf("Fail to parse the dumped configure: %v\n%s", err, dump)
+	}
+	if p := conf.Files[0].Schema.Edge.Props[1]; p.NullValues == nil {
+		t.Error("The empty null values should be kept")
+	}
+}
+
+func TestValidateMissingType(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(testdata(t, "v1rc1.yaml"), "    type: csv\n", "", 1)
+	_, errs := Validate(writeConfig(t, dir, content, map[string]string{"follow.csv": followCSV}), ParseOptions{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Please configure file type in: files[0].type") {
+		t.Errorf("The missing file type should be reported: %v", errs)
+	}
+}
+
+func TestScalarText(t *testing.T) {
+	tree, err := parseTree([]byte(`
+version: v1rc2
+description: no
+clientSettings:
+  concurrency: 0x10
+  connection:
+    password: 012345
+    user: ~
+files:
+  - csv:
+      withHeader: yes
+      delimiter: 1.10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf YAMLConfig
+	if err := decodeTree(tree, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Description != "no" || *conf.NebulaClientSettings.Connection.Password != "012345" || *conf.Files[0].CSV.Delimiter != "1.10" {
+		t.Errorf("String values should be decoded as they are written: %s, %s, %s", *conf.Description, *conf.NebulaClientSettings.Connection.Password, *conf.Files[0].CSV.Delimiter)
+	}
+	if *conf.NebulaClientSettings.Concurrency != 16 || !*conf.Files[0].CSV.WithHeader || conf.NebulaClientSettings.Connection.User != nil {
+		t.Error("Error non-string values")
+	}
+}
+
+func TestPasswordSecrets(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	secret := "s3cr3t-Pa55"
+	passwordFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(passwordFile, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("NEBULA_TEST_PASSWORD", secret)
+	defer os.Unsetenv("NEBULA_TEST_PASSWORD")
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+	defer logger.SetOutput(os.Stdout)
+
+	for _, source := range []string{"passwordFile: " + passwordFile, "passwordFromEnv: NEBULA_TEST_PASSWORD"} {
+		content := strings.Replace(testdata(t, "invalid.yaml"), "    address: 127.0.0.1:3699\n", "    address: 127.0.0.1:3699\n    "+source+"\n", 1)
+		conf, errs := Validate(writeConfig(t, dir, content, nil), ParseOptions{})
+		if len(errs) == 0 {
+			t.Fatal("The problems of the configure should be reported")
+		}
+		if *conf.NebulaClientSettings.Connection.Password != secret {
+			t.Errorf("Error password of %s: %s", source, *conf.NebulaClientSettings.Connection.Password)
+		}
+		for _, err := range errs {
+			if strings.Contains(err.Error(), secret) {
+				t.Errorf("The password of %s is in the error: %v", source, err)
+			}
+		}
+		dump, err := conf.Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(dump), secret) {
+			t.Errorf("The password of %s is dumped:\n%s", source, dump)
+		}
+	}
+	if strings.Contains(logs.String(), secret) {
+		t.Errorf("The password is logged:\n%s", logs.String())
+	}
+}
+
+func TestStrict(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(strings.Replace(testdata(t, "v1rc1.yaml"), "v1rc1", "v1rc2", 1), "withRanking", "withRankings", 1)
+	path := writeConfig(t, dir, content, map[string]string{"follow.csv": followCSV})
+	_, err := Parse(path)
+	if err == nil || !strings.Contains(err.Error(), "Unknown key: files[0].schema.edge.withRankings ("+path+" line 14)") {
+		t.Errorf("Unknown key should be rejected: %v", err)
+	}
+	if _, err = ParseWithOptions(path, ParseOptions{AllowUnknownKeys: true}); err != nil {
+		t.Errorf("Unknown key should be allowed: %v", err)
+	}
+
+	var conf YAMLConfig
+	data := []byte("{\n  \"version\": \"v1rc2\",\n  \"description\": \"batchsize\",\n  \"files\": [{\n    \"batchsize\": 10\n  }, {\n    \"batchsiz\": 10\n  }]\n}")
+	if err = DecodeJSON(data, &conf, true); err == nil || err.Error() != "Unknown key: files[1].batchsiz (line 7)" {
+		t.Errorf("Unknown key should be rejected: %v", err)
+	}
+	if err = DecodeJSON(data, &conf, false); err != nil {
+		t.Errorf("Unknown key should be allowed: %v", err)
+	}
+}
+
+func TestInferTypeRows(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(testdata(t, "v1rc1.yaml"), "            type: double\n", "", 1)
+	conf, err := Parse(writeConfig(t, dir, content, map[string]string{"follow.csv": followCSV}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := conf.Files[0].Schema.Edge.Props[0]; p.Type == nil || *p.Type != "string" {
+		t.Error("The prop without type should be string")
+	}
+
+	content = strings.Replace(content, "    type: csv\n", "    type: csv\n    csv:\n      inferTypeRows: 10\n", 1)
+	if conf, err = Parse(writeConfig(t, dir, content, nil)); err != nil {
+		t.Fatal(err)
+	}
+	edge := conf.Files[0].Schema.Edge
+	if edge.Props[0].Type != nil || !strings.HasSuffix(edge.String(), ",follow.likeness") {
+		t.Errorf("The prop type should be left to be inferred: %s", edge.String())
+	}
+}
+
+func TestFileSpace(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	conf, err := Parse(writeConfig(t, dir, testdata(t, "v1rc1.yaml"), map[string]string{"follow.csv": followCSV}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Files[0].Space != "test" {
+		t.Errorf("The space should be reset to clientSettings.space: %s", *conf.Files[0].Space)
+	}
+
+	checkInvalid(t, dir, testdata(t, "v1rc1.yaml"), []invalidCase{{"  space: test\n", "", "files[0].space or clientSettings.space"}})
+	content := strings.Replace(testdata(t, "v1rc1.yaml"), "  space: test\n", "", 1)
+	content = strings.Replace(content, "    type: csv\n", "    type: csv\n    space: fact\n", 1)
+	if conf, err = Parse(writeConfig(t, dir, content, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Files[0].Space != "fact" {
+		t.Errorf("Error space: %s", *conf.Files[0].Space)
+	}
+}
+
+func TestFileClientSettings(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(testdata(t, "v1rc1.yaml"), "    type: csv\n", "    type: csv\n    clientSettings:\n      concurrency: 1\n      timeout: 2m\n", 1)
+	conf, err := Parse(writeConfig(t, dir, content, map[string]string{"follow.csv": followCSV}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := conf.NebulaClientSettings.ForFile(conf.Files[0])
+	if *settings.Concurrency != 1 || settings.TimeoutDuration() != 2*time.Minute || *settings.Retry != *conf.NebulaClientSettings.Retry {
+		t.Errorf("Error client settings of file: %d, %v, %d", *settings.Concurrency, settings.TimeoutDuration(), *settings.Retry)
+	}
+	if *conf.NebulaClientSettings.Concurrency == 1 {
+		t.Error("The global client settings should not be changed")
+	}
+
+	checkInvalid(t, dir, content, []invalidCase{{"timeout: 2m", "timeout: 2", "files[0].clientSettings.timeout"}})
+}
+
+func TestStages(t *testing.T) {
+	name := func(s string) *string { return &s }
+	stage := 2
+	conf := YAMLConfig{Files: []*File{
+		{Name: name("follow"), DependsOn: []string{"person", "team"}},
+		{Name: name("person")},
+		{Name: name("team"), DependsOn: []string{"person"}},
+		{Name: name("audit"), Stage: &stage},
+	}}
+	if err := conf.resetStages(); err != nil {
+		t.Fatal(err)
+	}
+	var stages []string
+	for _, files := range conf.Stages() {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.String())
+		}
+		stages = append(stages, strings.Join(names, ","))
+	}
+	if s := strings.Join(stages, " | "); s != "person | team | follow,audit" {
+		t.Errorf("Error stages: %s", s)
+	}
+
+	conf.Files[1].DependsOn = []string{"follow"}
+	if err := conf.resetStages(); err == nil || !strings.Contains(err.Error(), "Circular dependency") {
+		t.Errorf("The circular dependency should be detected: %v", err)
+	}
+	conf.Files[1].DependsOn = []string{"vertex"}
+	if err := conf.resetStages(); err == nil || !strings.Contains(err.Error(), "unknown file: vertex") {
+		t.Errorf("The unknown file should be detected: %v", err)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	path := writeConfig(t, dir, testdata(t, "schemas.yaml"), map[string]string{"orders.csv": "user_id,user_name,order_id,amount\n1,Tom,100,9.5\n"})
+	conf, errs := Validate(path, ParseOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	f := conf.Files[0]
+	if n := conf.NumMappings(); n != 2 {
+		t.Errorf("Error number of mappings: %d", n)
+	}
+	if p := f.MappingFailDataPath(f.Schemas[0]); p != "./err/orders.0.csv" {
+		t.Errorf("Error fail data path of schemas[0]: %s", p)
+	}
+	if p := f.MappingFailDataPath(f.Schemas[1]); p != "./err/ordered.csv" {
+		t.Errorf("Error fail data path of schemas[1]: %s", p)
+	}
+	if name := f.MappingName(f.Schemas[1]); name != "schemas[1]" {
+		t.Errorf("Error name of schemas[1]: %s", name)
+	}
+
+	checkInvalid(t, dir, testdata(t, "schemas.yaml"), []invalidCase{
+		{"    schemas:\n", "    schema:\n      type: vertex\n    schemas:\n", "Only one of files[0].schema and files[0].schemas"},
+	})
+}
+
+func TestEdgeTypes(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	path := writeConfig(t, dir, testdata(t, "edge_types.yaml"), map[string]string{"relations.csv": "1,2,follow,90\n1,3,likes,9.5\n"})
+	conf, errs := Validate(path, ParseOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	edge := conf.Files[0].Schema.Edge
+	if s := edge.String(); s != ":SRC_VID,:DST_VID,:TYPE,follow.degree:int/like.likeness:double" {
+		t.Errorf("Error edge string: %s", s)
+	}
+	edges := edge.TypedEdges()
+	if e, ok := edges["likes"]; !ok || *e.Name != "like" {
+		t.Errorf("Error edge of value likes: %v", edges)
+	}
+	if s, err := edges["follow"].FormatValues([]string{"1", "2", "follow", "90"}, nil); err != nil || s != " 1->2:(90) " {
+		t.Errorf("Error values of follow: %s, %v", s, err)
+	}
+
+	checkInvalid(t, dir, testdata(t, "edge_types.yaml"), []invalidCase{
+		{"        typeColumn:\n          index: 2\n", "", "typeColumn.index"},
+	})
+}
+
+func TestTagConditions(t *testing.T) {
+	index, notEmpty := 1, true
+	name := func(s string) *string { return &s }
+	v := Vertex{Tags: []*Tag{
+		{Name: name("person")},
+		{Name: name("customer"), When: &TagCondition{Index: &index, Values: []string{"customer", "both"}}},
+		{Name: name("employee"), When: &TagCondition{Index: &index, Values: []string{"employee", "both"}}},
+		{Name: name("vip"), When: &TagCondition{Index: &index, NotEmpty: &notEmpty}},
+	}}
+	if !v.HasConditions() {
+		t.Error("The vertex should have conditions")
+	}
+	for _, c := range []struct {
+		record base.Record
+		tags   string
+	}{
+		{base.Record{"1", "customer"}, "[0 1 3]"},
+		{base.Record{"1", "both"}, "[0 1 2 3]"},
+		{base.Record{"1", " "}, "[0]"},
+		{base.Record{"1"}, "[0]"},
+	} {
+		if tags := fmt.Sprint(v.MatchTags(c.record)); tags != c.tags {
+			t.Errorf("Error tags of %v: %s, expected: %s", c.record, tags, c.tags)
+		}
+	}
+
+	if err := (&TagCondition{Index: &index}).validateAndReset("when"); err == nil {
+		t.Error("The condition without values or notEmpty should be invalid")
+	}
+}
+
+const ordersCSV = "tenant,customer,product,year,month,amount,currency,note\n"
+
+func TestExpr(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	conf, errs := Validate(writeConfig(t, dir, testdata(t, "expr.yaml"), map[string]string{"orders.csv": ordersCSV}), ParseOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	schema := conf.Files[0].Schema
+	if !schema.UsesConfiguredSchema() {
+		t.Error("The schema with expressions should be used as it is configured")
+	}
+	env := &expr.Env{Columns: map[string]int{"tenant": 0, "customer": 1, "year": 3, "month": 4, "amount": 5, "currency": 6}}
+	record := base.Record{"acme", "42", "7", "2020", "3", "1.5", "EUR", "gift"}
+	s, err := schema.Edge.FormatValues(record, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != ` hash("acme:42")->7@202003:(3,"gift") ` {
+		t.Errorf("Error values: %s", s)
+	}
+
+	checkInvalid(t, dir, testdata(t, "expr.yaml"), []invalidCase{
+		{"      withHeader: true\n", "      withHeader: false\n", "column tenant"},
+		{"          index: 2\n", "          index: 2\n          expr: $2\n", "Only one of"},
+		{"concat(tenant", "join(tenant", "Unknown function join"},
+	})
+}
+
+func TestFilter(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	content := strings.Replace(testdata(t, "expr.yaml"), "    csv:\n", "    filter: note != \"deleted\" && amount > 0\n    skippedRowsPath: ./skipped.csv\n    csv:\n", 1)
+	conf, errs := Validate(writeConfig(t, dir, content, map[string]string{"orders.csv": ordersCSV}), ParseOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	file := conf.Files[0]
+	env := &expr.Env{Columns: map[string]int{"amount": 5, "note": 7}}
+	for _, c := range []struct {
+		record base.Record
+		skip   bool
+	}{
+		{base.Record{"acme", "42", "7", "2020", "3", "1.5", "EUR", "gift"}, false},
+		{base.Record{"acme", "42", "7", "2020", "3", "1.5", "EUR", "deleted"}, true},
+		{base.Record{"acme", "42", "7", "2020", "3", "0", "EUR", "gift"}, true},
+	} {
+		if skip, err := file.Skip(c.record, env); err != nil || skip != c.skip {
+			t.Errorf("Error skip of %v: %v, %v", c.record, skip, err)
+		}
+	}
+	if _, err := file.Skip(base.Record{"acme"}, env); err == nil {
+		t.Error("The row without the columns of the filter should fail")
+	}
+
+	checkInvalid(t, dir, content, []invalidCase{{"amount > 0", "amount >", "files[0].filter"}})
+}
+
+func TestNullValues(t *testing.T) {
+	str := func(s string) *string { return &s }
+	for _, c := range []struct {
+		prop     Prop
+		cell     string
+		expected string
+		err      string
+	}{
+		{Prop{Name: str("age"), Type: str("int")}, "", "", "null value"},
+		{Prop{Name: str("age"), Type: str("int"), Default: str("0")}, "", "0", ""},
+		{Prop{Name: str("age"), Type: str("int"), Default: str("0"), NullValues: []string{`\N`, "NULL"}}, `\N`, "0", ""},
+		{Prop{Name: str("age"), Type: str("int"), OnNull: str("skip-row")}, "", "", "skipped"},
+		{Prop{Name: str("name"), Type: str("string")}, "", `""`, ""},
+		{Prop{Name: str("name"), Type: str("string"), Default: str("unknown"), NullValues: []string{""}}, "", `"unknown"`, ""},
+		{Prop{Name: str("name"), Type: str("string"), Default: str("unknown"), OnNull: str("ERROR"), NullValues: []string{"NULL"}}, "NULL", "", "null value"},
+	} {
+		if err := c.prop.validateAndReset("prop", 0); err != nil {
+			t.Fatal(err)
+		}
+		v, err := c.prop.FormatValue(base.Record{c.cell}, nil)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Error of %s(%q) should contain %q: %v", *c.prop.Name, c.cell, c.err, err)
+			}
+		} else if err != nil || v != c.expected {
+			t.Errorf("Error value of %s(%q): %s, %v, expected: %s", *c.prop.Name, c.cell, v, err, c.expected)
+		}
+	}
+
+	index := 0
+	skip := Prop{Name: str("age"), Type: str("int"), Index: &index, OnNull: str("skip-row")}
+	tag := Tag{Name: str("person"), Props: []*Prop{&skip}}
+	if _, err := tag.FormatValues(base.Record{""}, nil); !errors.Is(err, ErrSkipRow) {
+		t.Errorf("The row should be skipped: %v", err)
+	}
+	if err := (&Prop{Name: str("age"), OnNull: str("default")}).validateAndReset("prop", 0); err == nil || !strings.Contains(err.Error(), "prop.default") {
+		t.Errorf("The default value should be required: %v", err)
+	}
+	if err := (&Prop{Name: str("age"), OnNull: str("ignore")}).validateAndReset("prop", 0); err == nil {
+		t.Error("The invalid onNull should be rejected")
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	str := func(s string) *string { return &s }
+	for _, c := range []struct {
+		prop     Prop
+		cell     string
+		expected string
+		err      string
+	}{
+		{Prop{Name: str("ok"), Type: str("bool")}, "TRUE", "true", ""},
+		{Prop{Name: str("ok"), Type: str("bool")}, "0", "false", ""},
+		{Prop{Name: str("ok"), Type: str("bool")}, "yes", "", "invalid bool"},
+		{Prop{Name: str("ok"), Type: str("bool"), TrueValues: []string{"Y"}, FalseValues: []string{"N"}}, " y ", "true", ""},
+		{Prop{Name: str("ok"), Type: str("bool"), TrueValues: []string{"Y"}, FalseValues: []string{"N"}}, "true", "", "invalid bool"},
+		{Prop{Name: str("price"), Type: str("double")}, "12.50", "12.5", ""},
+		{Prop{Name: str("price"), Type: str("double")}, "1e3", "1000", ""},
+		{Prop{Name: str("price"), Type: str("float")}, "12,5", "", "invalid float"},
+		{Prop{Name: str("price"), Type: str("float")}, "NaN", "", "invalid float"},
+		{Prop{Name: str("price"), Type: str("double"), DecimalSeparator: str(",")}, "12,5", "12.5", ""},
+		{Prop{Name: str("price"), Type: str("double"), DecimalSeparator: str(",")}, "1.234,5", "", "decimal separator"},
+		{Prop{Name: str("at"), Type: str("timestamp")}, "1577836800", "1577836800", ""},
+		{Prop{Name: str("at"), Type: str("timestamp")}, "2020-01-01 00:00:00", "1577836800", ""},
+		{Prop{Name: str("at"), Type: str("timestamp")}, "01/01/2020", "", "invalid timestamp"},
+		{Prop{Name: str("at"), Type: str("timestamp"), Layouts: []string{"02/01/2006", "2006-01"}}, "2020-01", "1577836800", ""},
+	} {
+		if err := c.prop.validateAndReset("prop", 0); err != nil {
+			t.Fatal(err)
+		}
+		v, err := c.prop.FormatValue(base.Record{c.cell}, nil)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Error of %s(%q) should contain %q: %v", *c.prop.Name, c.cell, c.err, err)
+			}
+		} else if err != nil || v != c.expected {
+			t.Errorf("Error value of %s(%q): %s, %v, expected: %s", *c.prop.Name, c.cell, v, err, c.expected)
+		}
+	}
+
+	if err := (&Prop{Name: str("price"), Type: str("double"), DecimalSeparator: str(",,")}).validateAndReset("prop", 0); err == nil {
+		t.Error("The decimal separator of several characters should be rejected")
+	}
+	if err := (&Prop{Name: str("ok"), Type: str("bool"), TrueValues: []string{"y"}, FalseValues: []string{"Y"}}).validateAndReset("prop", 0); err == nil {
+		t.Error("The same literal of true and false should be rejected")
+	}
+}
+
+func TestParseDateTimestamps(t *testing.T) {
+	str := func(s string) *string { return &s }
+	for _, c := range []struct {
+		prop     Prop
+		cell     string
+		expected string
+	}{
+		{Prop{Name: str("at"), Type: str("date-timestamp:2006-01-02")}, "2020-01-01", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:02-Jan-2006")}, "01-Jan-2020", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:RFC3339")}, "2020-01-01T08:00:00+08:00", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:datetime"), Layouts: []string{"date"}}, "2020-01-01", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:datetime"), TimeZone: str("+08:00")}, "2020-01-01 08:00:00", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:date"), TimeZone: str("UTC")}, "2020-01-01", "1577836800"},
+		{Prop{Name: str("at"), Type: str("date-timestamp:epoch-millis")}, "1577836800123", "1577836800"},
+		{Prop{Name: str("at"), Type: str("timestamp"), Layouts: []string{"epoch-millis"}}, "1577836800123", "1577836800"},
+		{Prop{Name: str("at"), Type: str("timestamp"), TimeZone: str("-01:00")}, "2019-12-31 23:00:00", "1577836800"},
+	} {
+		if err := c.prop.validateAndReset("prop", 0); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := c.prop.FormatValue(base.Record{c.cell}, nil); err != nil || v != c.expected {
+			t.Errorf("Error value of %s(%q): %s, %v, expected: %s", *c.prop.Type, c.cell, v, err, c.expected)
+		}
+	}
+
+	// The layout isn't truncated to the length of the value any more
+	p := Prop{Name: str("at"), Type: str("date-timestamp:2006-01-02")}
+	if err := p.validateAndReset("prop", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.FormatValue(base.Record{"2020-01-01 10:00:00"}, nil); err == nil || !strings.Contains(err.Error(), "invalid date-timestamp") {
+		t.Errorf("The value not matching the layout should fail: %v", err)
+	}
+	if err := (&Prop{Name: str("at"), Type: str("timestamp"), TimeZone: str("Mars/Olympus")}).validateAndReset("prop", 0); err == nil || !strings.Contains(err.Error(), "prop.timeZone") {
+		t.Errorf("The unknown time zone should be rejected: %v", err)
+	}
+}
+
+func TestLookups(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	lookups := `    lookups:
+      - name: countries
+        path: ./countries.csv
+        key: code
+        value: name
+      - name: products
+        path: ./products.jsonl
+        key: id
+        value: category
+        default: other
+`
+	content := strings.Replace(testdata(t, "expr.yaml"), "    csv:\n", lookups+"    csv:\n", 1)
+	content = strings.Replace(content, "          index: 7\n", "          expr: lookup(\"products\", product)\n", 1)
+	path := writeConfig(t, dir, content, map[string]string{
+		"orders.csv":      ordersCSV,
+		"countries.csv":   "code,name\nFR,France\n",
+		"products.jsonl":  `{"id": 7, "category": "toys"}` + "\n",
+		"categories.json": "",
+	})
+	conf, errs := Validate(path, ParseOptions{})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	products := conf.Files[0].Lookups[1]
+	if *products.Type != LOOKUP_JSONL || products.MissingAction() != ON_NULL_DEFAULT {
+		t.Errorf("Error lookup: %s, %s", *products.Type, products.MissingAction())
+	}
+	if countries := conf.Files[0].Lookups[0]; *countries.Type != LOOKUP_CSV || *countries.Delimiter != "," || countries.MissingAction() != ON_NULL_ERROR {
+		t.Errorf("Error lookup: %s, %s", *countries.Type, countries.MissingAction())
+	}
+
+	checkInvalid(t, dir, content, []invalidCase{
+		{`lookup("products", product)`, `lookup("brands", product)`, "unknown lookup: brands"},
+		{`lookup("products", product)`, `lookup(tenant, product)`, "should be a string"},
+		{"        default: other\n", "        onMissing: default\n", "lookups[1].default"},
+		{"- name: products", "- name: countries", "Duplicate lookup name"},
+		{"./products.jsonl", "./categories.json\n        type: xml", "lookups[1].type"},
+	})
+}
+
+func TestExplode(t *testing.T) {
+	index, yes := 1, true
+	pipe, empty := "|", ""
+	for _, c := range []struct {
+		explode Explode
+		err     string
+	}{
+		{Explode{Index: &index, Delimiter: &pipe}, ""},
+		{Explode{Index: &index, JSON: &yes}, ""},
+		{Explode{Delimiter: &pipe}, "explode.index"},
+		{Explode{Index: &index}, "explode.delimiter"},
+		{Explode{Index: &index, Delimiter: &empty}, "explode.delimiter"},
+		{Explode{Index: &index, Delimiter: &pipe, JSON: &yes}, "Only one of"},
+	} {
+		err := c.explode.validateAndReset("edge.explode")
+		if c.err == "" && err != nil {
+			t.Errorf("Error validation of %v: %v", c.explode, err)
+		} else if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("Error of %v should contain %q: %v", c.explode, c.err, err)
+		}
+	}
+}
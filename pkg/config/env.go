@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envPattern matches $$, ${VAR}, ${VAR:-default} and ${VAR:?message}.
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)([^}]*))?\}`)
+
+// expandEnv substitutes environment variables in the string value at path.
+// ${VAR:-default} falls back to default when VAR is unset or empty, ${VAR:?msg}
+// fails with msg, and $$ is an escaped $.
+func expandEnv(path string, s string) (interface{}, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var err error
+	whole := true
+	expanded := envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "$$" {
+			whole = false
+			return "$"
+		}
+		sub := envPattern.FindStringSubmatch(m)
+		name, op, arg := sub[1], sub[2], sub[3]
+		if len(m) != len(s) {
+			whole = false
+		}
+		if v, ok := os.LookupEnv(name); ok && (v != "" || op == "") {
+			return v
+		}
+		switch op {
+		case ":-":
+			return arg
+		case ":?":
+			if err == nil {
+				err = fmt.Errorf("Environment variable %s in %s is required: %s", name, path, arg)
+			}
+		default:
+			if err == nil {
+				err = fmt.Errorf("Environment variable %s in %s is not set, use ${%s:-default} to give a default value", name, path, name)
+			}
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A value which is entirely a placeholder takes the type of the substituted
+	// text, e.g. concurrency: ${CONCURRENCY}
+	if whole && expanded != s {
+		return typedScalar(expanded), nil
+	}
+	return expanded, nil
+}
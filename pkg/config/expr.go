@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
+)
+
+// envFuncs are the functions which the reader registers in the environment of
+// the expressions besides the builtin ones.
+var envFuncs = []string{"lookup"}
+
+func compileExpr(src, prefix string) (*expr.Expr, error) {
+	x, err := expr.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s.expr: %v", prefix, err)
+	}
+	return x, nil
+}
+
+// cellValue returns the column of the record at index, or the value of the
+// expression if it is configured instead.
+func cellValue(record base.Record, index *int, x *expr.Expr, env *expr.Env) (string, error) {
+	if x != nil {
+		return x.EvalString(record, env)
+	}
+	if index == nil {
+		return "", fmt.Errorf("No column index")
+	}
+	if *index >= len(record) {
+		return "", fmt.Errorf("Index %d out range %d of record(%v)", *index, len(record), record)
+	}
+	return record[*index], nil
+}
+
+// Value returns the VID of the record before it is wrapped by the function.
+func (v *VID) Value(record base.Record, env *expr.Env) (string, error) {
+	return cellValue(record, v.Index, v.expr, env)
+}
+
+// FormatValue formats the VID of the record, which is wrapped by the function
+// if it is configured.
+func (v *VID) FormatValue(record base.Record, env *expr.Env) (string, error) {
+	cell, err := v.Value(record, env)
+	if err != nil {
+		return "", err
+	}
+	function := ""
+	if v.Function != nil {
+		//TODO(yee): differentiate string and integer column type, find and compare src/dst vertex column with property
+		function = *v.Function
+	}
+	return base.FormatVID(function, cell), nil
+}
+
+// exprs returns the expressions of the schema.
+func (s *Schema) exprs() []*expr.Expr {
+	var exprs []*expr.Expr
+	add := func(x *expr.Expr) {
+		if x != nil {
+			exprs = append(exprs, x)
+		}
+	}
+	if v := s.Vertex; v != nil && s.IsVertex() && v.VID != nil {
+		add(v.VID.expr)
+	}
+	if e := s.Edge; e != nil && !s.IsVertex() {
+		for _, vid := range []*VID{e.SrcVID, e.DstVID} {
+			if vid != nil {
+				add(vid.expr)
+			}
+		}
+		if e.Rank != nil {
+			add(e.Rank.expr)
+		}
+	}
+	for _, p := range s.props() {
+		add(p.expr)
+	}
+	return exprs
+}
+
+// props returns the props of the schema, of all the tags or edge types.
+func (s *Schema) props() []*Prop {
+	var props []*Prop
+	add := func(ps []*Prop) {
+		for _, p := range ps {
+			if p != nil {
+				props = append(props, p)
+			}
+		}
+	}
+	if v := s.Vertex; v != nil && s.IsVertex() {
+		for _, t := range v.Tags {
+			if t != nil {
+				add(t.Props)
+			}
+		}
+	}
+	if e := s.Edge; e != nil && !s.IsVertex() {
+		add(e.Props)
+		for _, t := range e.Types {
+			if t != nil {
+				add(t.Props)
+			}
+		}
+	}
+	return props
+}
+
+// UsesConfiguredSchema reports whether the rows are mapped by the schema as it
+// is configured, instead of by the header, which is the case of the edge
+// types, the conditional tags, the expressions and the options of the values.
+func (s *Schema) UsesConfiguredSchema() bool {
+	if s.IsVertex() {
+		if s.Vertex != nil && s.Vertex.HasConditions() {
+			return true
+		}
+	} else if s.Edge != nil && s.Edge.HasTypes() {
+		return true
+	}
+	if len(s.exprs()) > 0 {
+		return true
+	}
+	for _, p := range s.props() {
+		if p.configuresNull() || p.configuresParsing() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExprNames checks that the columns are referred by names only if they
+// are named by the header.
+func (s *Schema) checkExprNames(withHeader bool, prefix string) error {
+	return checkExprNames(s.exprs(), withHeader, prefix)
+}
+
+func checkExprNames(exprs []*expr.Expr, withHeader bool, prefix string) error {
+	if withHeader {
+		return nil
+	}
+	for _, x := range exprs {
+		if names := x.Names(); len(names) > 0 {
+			return fmt.Errorf("%s: column %s in expression %q can only be referred by name with csv.withHeader, use $N instead", prefix, names[0], x.String())
+		}
+	}
+	return nil
+}
+
+// Skip reports whether the row is skipped by the filter of the file.
+func (f *File) Skip(record base.Record, env *expr.Env) (bool, error) {
+	if f.filter == nil {
+		return false, nil
+	}
+	v, err := f.filter.Eval(record, env)
+	if err != nil {
+		return false, err
+	}
+	return !expr.Truthy(v), nil
+}
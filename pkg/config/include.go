@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// loadTree reads the configuration file and merges the files it includes.
+// Included files are merged in order as the base of the including file, and
+// their files lists are concatenated before the files of the including file.
+// The lines of the keys of the merged configuration are collected in lines.
+func loadTree(filename string, visiting []string, lines keyLines) (yaml.MapSlice, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range visiting {
+		if v == abs {
+			return nil, fmt.Errorf("Circular include of %s", filename)
+		}
+	}
+	visiting = append(visiting, abs)
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parseTree(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if _, err = walkStrings(tree, "", expandEnv); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	if len(visiting) > 1 {
+		rebasePaths(tree, filepath.Dir(abs))
+	}
+
+	includes, tree := popKey(tree, "include")
+	if includes == nil {
+		lines.merge(fileLines(filename, content), 0)
+		return tree, nil
+	}
+	var paths []string
+	switch v := includes.(type) {
+	case string:
+		paths = []string{v}
+	case []interface{}:
+		for i := range v {
+			p, ok := v[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: include[%d] must be a file path", filename, i)
+			}
+			paths = append(paths, p)
+		}
+	default:
+		return nil, fmt.Errorf("%s: include must be a file path or a list of file paths", filename)
+	}
+
+	var base yaml.MapSlice
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(filename), p)
+		}
+		includedLines := make(keyLines)
+		included, err := loadTree(p, visiting, includedLines)
+		if err != nil {
+			return nil, err
+		}
+		lines.merge(includedLines, numFiles(base))
+		base = mergeTree(base, included, true)
+	}
+	// The keys of the including file replace those of the included ones
+	lines.merge(fileLines(filename, content), numFiles(base))
+	return mergeTree(base, tree, true), nil
+}
+
+// rebasePaths joins the relative paths of the data and lookup files of an
+// included file to its directory, since they are resolved against the
+// directory of the main configuration file otherwise.
+func rebasePaths(tree yaml.MapSlice, dir string) {
+	rebase := func(node interface{}) {
+		m, ok := node.(yaml.MapSlice)
+		if !ok {
+			return
+		}
+		for i := range m {
+			if p, ok := m[i].Value.(string); ok && m[i].Key == "path" && p != "" && !filepath.IsAbs(p) && !strings.Contains(p, "://") {
+				m[i].Value = filepath.Join(dir, p)
+			}
+		}
+	}
+	files, _ := getKey(tree, "files")
+	list, _ := files.([]interface{})
+	for _, f := range list {
+		rebase(f)
+		m, _ := f.(yaml.MapSlice)
+		lookups, _ := getKey(m, "lookups")
+		l, _ := lookups.([]interface{})
+		for i := range l {
+			rebase(l[i])
+		}
+	}
+}
+
+// numFiles returns the number of the files of the tree.
+func numFiles(tree yaml.MapSlice) int {
+	files, _ := getKey(tree, "files")
+	list, _ := files.([]interface{})
+	return len(list)
+}
+
+func popKey(tree yaml.MapSlice, key string) (interface{}, yaml.MapSlice) {
+	for i := range tree {
+		if tree[i].Key == key {
+			return tree[i].Value, append(tree[:i:i], tree[i+1:]...)
+		}
+	}
+	return nil, tree
+}
+
+// mergeTree merges over into base, mappings are merged recursively and other
+// values of over replace those of base.
+func mergeTree(base, over yaml.MapSlice, top bool) yaml.MapSlice {
+	result := append(yaml.MapSlice(nil), base...)
+	for _, item := range over {
+		merged := false
+		for i := range result {
+			if result[i].Key != item.Key {
+				continue
+			}
+			b, bok := result[i].Value.(yaml.MapSlice)
+			o, ook := item.Value.(yaml.MapSlice)
+			bl, blok := result[i].Value.([]interface{})
+			ol, olok := item.Value.([]interface{})
+			switch {
+			case bok && ook:
+				result[i].Value = mergeTree(b, o, false)
+			case top && item.Key == "files" && blok && olok:
+				result[i].Value = append(append([]interface{}(nil), bl...), ol...)
+			default:
+				result[i].Value = item.Value
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// copyByYAML deep copies the configure struct pointed by src into dst.
+func copyByYAML(src interface{}, dst interface{}) error {
+	b, err := yaml.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, dst)
+}
+
+// mergeStruct fills the nil fields of dst with those of src. Nested structs
+// are merged recursively, while lists of dst replace those of src as a whole.
+// It returns the paths of the fields filled under prefix.
+func mergeStruct(dst, src reflect.Value, prefix string) []string {
+	for dst.Kind() == reflect.Ptr {
+		dst, src = dst.Elem(), src.Elem()
+	}
+	var filled []string
+	for i := 0; i < dst.NumField(); i++ {
+		df, sf := dst.Field(i), src.Field(i)
+		if !df.CanSet() {
+			continue
+		}
+		path := fmt.Sprintf("%s.%s", prefix, yamlName(dst.Type().Field(i)))
+		switch df.Kind() {
+		case reflect.Ptr:
+			if df.IsNil() {
+				df.Set(sf)
+				if !sf.IsNil() {
+					filled = append(filled, path)
+				}
+			} else if !sf.IsNil() && df.Elem().Kind() == reflect.Struct {
+				filled = append(filled, mergeStruct(df, sf, path)...)
+			}
+		case reflect.Slice, reflect.Map:
+			if df.IsNil() {
+				df.Set(sf)
+				if !sf.IsNil() {
+					filled = append(filled, path)
+				}
+			}
+		}
+	}
+	return filled
+}
+
+func (t *Templates) schema(name string) (*Schema, error) {
+	if t != nil {
+		if s, ok := t.Schemas[name]; ok && s != nil {
+			var c Schema
+			if err := copyByYAML(s, &c); err != nil {
+				return nil, err
+			}
+			c.Template = nil
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("Schema template %s doesn't exist in templates.schemas", name)
+}
+
+func (t *Templates) csv(name string) (*CSVConfig, error) {
+	if t != nil {
+		if c, ok := t.CSV[name]; ok && c != nil {
+			var cc CSVConfig
+			if err := copyByYAML(c, &cc); err != nil {
+				return nil, err
+			}
+			cc.Template = nil
+			return &cc, nil
+		}
+	}
+	return nil, fmt.Errorf("CSV template %s doesn't exist in templates.csv", name)
+}
+
+// applyTemplates resolves the schema and csv templates referred by the file,
+// the settings in the file take precedence over those of the template.
+func (f *File) applyTemplates(templates *Templates, prefix string) error {
+	f.templateFields = make(map[string]string)
+	if f.Schema != nil {
+		if err := f.Schema.applyTemplate(templates, fmt.Sprintf("%s.schema", prefix), f.templateFields); err != nil {
+			return err
+		}
+	}
+	for i, s := range f.Schemas {
+		if s == nil {
+			continue
+		}
+		if err := s.applyTemplate(templates, fmt.Sprintf("%s.schemas[%d]", prefix, i), f.templateFields); err != nil {
+			return err
+		}
+	}
+	if f.CSV != nil && f.CSV.Template != nil {
+		name := *f.CSV.Template
+		t, err := templates.csv(name)
+		if err != nil {
+			return fmt.Errorf("%s.csv.template: %v", prefix, err)
+		}
+		f.CSV.Template = nil
+		for _, path := range mergeStruct(reflect.ValueOf(f.CSV), reflect.ValueOf(t), fmt.Sprintf("%s.csv", prefix)) {
+			f.templateFields[path] = name
+		}
+	}
+	return nil
+}
+
+// applyTemplate resolves the template referred by the schema, and records the
+// template of the fields filled by it in fields.
+func (s *Schema) applyTemplate(templates *Templates, prefix string, fields map[string]string) error {
+	if s.Template == nil {
+		return nil
+	}
+	name := *s.Template
+	t, err := templates.schema(name)
+	if err != nil {
+		return fmt.Errorf("%s.template: %v", prefix, err)
+	}
+	s.Template = nil
+	for _, path := range mergeStruct(reflect.ValueOf(s), reflect.ValueOf(t), prefix) {
+		fields[path] = name
+	}
+	return nil
+}
+
+// fromTemplate tells the template of the field the error is about, if the
+// field is filled by the template.
+func (f *File) fromTemplate(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for path, name := range f.templateFields {
+		// The path is the field itself or a field inside it, e.g. a prop of
+		// the props filled by the template
+		if mentions(msg, path) {
+			return fmt.Errorf("%v (from template %s)", err, name)
+		}
+	}
+	return err
+}
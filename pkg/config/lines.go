@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// keyLines are the places the keys of the configuration are written in by
+// their paths, e.g. files[1].batchSize in ./config.yaml line 13.
+type keyLines map[string]string
+
+// at returns the place of the key as the suffix of a message.
+func (l keyLines) at(path string) string {
+	if place, ok := l[path]; ok {
+		return fmt.Sprintf(" (%s)", place)
+	}
+	return ""
+}
+
+// fileLines indexes the lines of the keys of the configuration file.
+func fileLines(filename string, content []byte) keyLines {
+	lines := make(keyLines)
+	var root lineNode
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return lines
+	}
+	var walk func(n *lineNode, path []interface{})
+	walk = func(n *lineNode, path []interface{}) {
+		for k, child := range n.mapping {
+			p := append(path[:len(path):len(path)], k.name)
+			if k.line > 0 {
+				lines[renderPath(p)] = fmt.Sprintf("%s line %d", filename, k.line)
+			}
+			if child != nil {
+				walk(child, p)
+			}
+		}
+		for i, child := range n.sequence {
+			p := append(path[:len(path):len(path)], i)
+			if child == nil {
+				continue
+			}
+			if child.line > 0 {
+				lines[renderPath(p)] = fmt.Sprintf("%s line %d", filename, child.line)
+			}
+			walk(child, p)
+		}
+	}
+	walk(&root, nil)
+	return lines
+}
+
+var filesPathPattern = regexp.MustCompile(`^files\[(\d+)\]`)
+
+// merge adds the lines of another file, whose files are after the first n
+// files of the merged configuration.
+func (l keyLines) merge(other keyLines, n int) {
+	for path, place := range other {
+		if m := filesPathPattern.FindStringSubmatch(path); m != nil {
+			i, _ := strconv.Atoi(m[1])
+			path = fmt.Sprintf("files[%d]%s", i+n, path[len(m[0]):])
+		}
+		l[path] = place
+	}
+}
+
+// lineNode is a node of the YAML document with the lines of its keys and
+// elements.
+type lineNode struct {
+	line     int
+	mapping  map[lineKey]*lineNode
+	sequence []*lineNode
+}
+
+// lineKey is a key of a mapping with its line.
+type lineKey struct {
+	name string
+	line int
+}
+
+func (n *lineNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	n.line = nodeLine(unmarshal)
+	if unmarshal(&n.mapping) != nil {
+		n.mapping = nil
+	}
+	if unmarshal(&n.sequence) != nil {
+		n.sequence = nil
+	}
+	return nil
+}
+
+func (k *lineKey) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	k.line = nodeLine(unmarshal)
+	return unmarshal(&k.name)
+}
+
+// nodeLine returns the line of the node decoded by unmarshal. Nothing can be
+// decoded into a channel, and the error tells the line of the node.
+func nodeLine(unmarshal func(interface{}) error) int {
+	line := 0
+	if typeErr, ok := unmarshal(new(chan int)).(*yaml.TypeError); ok && len(typeErr.Errors) > 0 {
+		fmt.Sscanf(typeErr.Errors[0], "line %d:", &line)
+	}
+	return line
+}
+
+// invalidValue is a value of the configuration which can't be decoded into its
+// field.
+type invalidValue struct {
+	path string
+	msg  string
+}
+
+var typeErrorLinePattern = regexp.MustCompile(`^line \d+: `)
+
+// invalidValues reports the values of the tree which can't be decoded into the
+// fields of the configure struct of type t.
+func invalidValues(node interface{}, t reflect.Type, path []interface{}) []invalidValue {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	var values []invalidValue
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		switch t.Kind() {
+		case reflect.Struct:
+			for _, item := range n {
+				key := fmt.Sprint(item.Key)
+				// The unknown keys are reported separately
+				if f, ok := findYAMLField(t, key); ok && yamlName(f) == key {
+					values = append(values, invalidValues(item.Value, f.Type, append(path[:len(path):len(path)], key))...)
+				}
+			}
+			return values
+		case reflect.Map:
+			for _, item := range n {
+				values = append(values, invalidValues(item.Value, t.Elem(), append(path[:len(path):len(path)], fmt.Sprint(item.Key)))...)
+			}
+			return values
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i := range n {
+				values = append(values, invalidValues(n[i], t.Elem(), append(path[:len(path):len(path)], i))...)
+			}
+			return values
+		}
+	}
+
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		return []invalidValue{{renderPath(path), err.Error()}}
+	}
+	typeErr, ok := yaml.Unmarshal(b, reflect.New(t).Interface()).(*yaml.TypeError)
+	if !ok {
+		return nil
+	}
+	msgs := make([]string, 0, len(typeErr.Errors))
+	for _, e := range typeErr.Errors {
+		msgs = append(msgs, typeErrorLinePattern.ReplaceAllString(e, ""))
+	}
+	return []invalidValue{{renderPath(path), strings.Join(msgs, "; ")}}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
+)
+
+// The types of lookup files
+const (
+	LOOKUP_CSV   = "csv"
+	LOOKUP_JSONL = "jsonl"
+)
+
+// Lookup is a table of the values by the keys, which is loaded from a CSV file
+// with a header or a JSON lines file, and is used in expressions by
+// lookup("name", key).
+type Lookup struct {
+	Name *string `json:"name" yaml:"name"`
+	Path *string `json:"path" yaml:"path"`
+	// Type is csv or jsonl, by the extension of the path by default
+	Type      *string `json:"type" yaml:"type"`
+	Delimiter *string `json:"delimiter" yaml:"delimiter"`
+	// Key and Value are the columns of csv files, or the fields of jsonl files
+	Key   *string `json:"key" yaml:"key"`
+	Value *string `json:"value" yaml:"value"`
+	// OnMissing is the action for the missing keys: default, skip-row or
+	// error, which is default if Default is configured, or error otherwise
+	OnMissing *string `json:"onMissing" yaml:"onMissing"`
+	Default   *string `json:"default" yaml:"default"`
+}
+
+// MissingAction returns the action for the missing keys.
+func (l *Lookup) MissingAction() string {
+	if l.OnMissing != nil {
+		return *l.OnMissing
+	}
+	if l.Default != nil {
+		return ON_NULL_DEFAULT
+	}
+	return ON_NULL_ERROR
+}
+
+func (l *Lookup) validateAndReset(dir, prefix string) error {
+	if l.Name == nil || *l.Name == "" {
+		return fmt.Errorf("Please configure the lookup name in: %s.name", prefix)
+	}
+	if l.Path == nil {
+		return fmt.Errorf("Please configure the lookup path in: %s.path", prefix)
+	}
+	if !base.FileExists(*l.Path) {
+		path := filepath.Join(dir, *l.Path)
+		if !base.FileExists(path) {
+			return fmt.Errorf("Lookup file(%s) doesn't exist", *l.Path)
+		}
+		l.Path = &path
+	}
+	if l.Type == nil {
+		t := LOOKUP_CSV
+		switch strings.ToLower(filepath.Ext(*l.Path)) {
+		case ".jsonl", ".ndjson":
+			t = LOOKUP_JSONL
+		}
+		l.Type = &t
+	}
+	*l.Type = strings.ToLower(*l.Type)
+	switch *l.Type {
+	case LOOKUP_CSV:
+		if l.Delimiter == nil {
+			d := ","
+			l.Delimiter = &d
+		}
+		if len([]rune(*l.Delimiter)) != 1 {
+			return fmt.Errorf("Invalid %s.delimiter: %q, expected a character", prefix, *l.Delimiter)
+		}
+	case LOOKUP_JSONL:
+	default:
+		return fmt.Errorf("Invalid %s.type: %s, expected %s or %s", prefix, *l.Type, LOOKUP_CSV, LOOKUP_JSONL)
+	}
+	if l.Key == nil || l.Value == nil {
+		return fmt.Errorf("Please configure the key and value in: %s.key and %s.value", prefix, prefix)
+	}
+	if l.OnMissing != nil {
+		*l.OnMissing = strings.ToLower(*l.OnMissing)
+	}
+	switch l.MissingAction() {
+	case ON_NULL_DEFAULT:
+		if l.Default == nil {
+			return fmt.Errorf("Please configure %s.default for %s.onMissing: %s", prefix, prefix, ON_NULL_DEFAULT)
+		}
+	case ON_NULL_SKIP_ROW, ON_NULL_ERROR:
+	default:
+		return fmt.Errorf("Invalid %s.onMissing: %s, expected %s, %s or %s", prefix, *l.OnMissing, ON_NULL_DEFAULT, ON_NULL_SKIP_ROW, ON_NULL_ERROR)
+	}
+	return nil
+}
+
+// validateAndResetLookups checks the lookups of the file, and the functions
+// and the lookups called by the expressions of the file.
+func (f *File) validateAndResetLookups(dir, prefix string) error {
+	names := make(map[string]bool, len(f.Lookups))
+	for i, l := range f.Lookups {
+		lookupPrefix := fmt.Sprintf("%s.lookups[%d]", prefix, i)
+		if l == nil {
+			return fmt.Errorf("Please configure the lookup in: %s", lookupPrefix)
+		}
+		if err := l.validateAndReset(dir, lookupPrefix); err != nil {
+			return err
+		}
+		if names[*l.Name] {
+			return fmt.Errorf("Duplicate lookup name in %s.name: %s", lookupPrefix, *l.Name)
+		}
+		names[*l.Name] = true
+	}
+
+	var exprs []*expr.Expr
+	if f.filter != nil {
+		exprs = append(exprs, f.filter)
+	}
+	for _, s := range f.Mappings() {
+		exprs = append(exprs, s.exprs()...)
+	}
+	for _, x := range exprs {
+		if err := x.CheckFuncs(envFuncs...); err != nil {
+			return fmt.Errorf("%s: %v in expression %q", prefix, err, x.String())
+		}
+		for _, args := range x.Args("lookup") {
+			if len(args) != 2 {
+				return fmt.Errorf("%s: lookup in expression %q expects 2 arguments, the name and the key", prefix, x.String())
+			}
+			name, ok := args[0].(string)
+			if !ok {
+				return fmt.Errorf("%s: the name of lookup in expression %q should be a string", prefix, x.String())
+			}
+			if !names[name] {
+				return fmt.Errorf("%s: expression %q refers to an unknown lookup: %s, configure it in %s.lookups", prefix, x.String(), name, prefix)
+			}
+		}
+	}
+	return nil
+}
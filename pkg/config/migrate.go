@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LatestVersion is the configuration version this importer is written for,
+// configurations of older versions are migrated to it.
+const LatestVersion = "v1rc2"
+
+// migration upgrades the configuration tree of version from to version to.
+type migration struct {
+	from    string
+	to      string
+	migrate func(tree yaml.MapSlice) ([]string, error)
+}
+
+var migrations = []migration{
+	{from: "v1rc1", to: "v1rc2", migrate: migrateV1rc1},
+}
+
+// SupportedVersions returns the configuration versions which can be parsed,
+// from the oldest to the latest.
+func SupportedVersions() []string {
+	var versions []string
+	for _, m := range migrations {
+		versions = append(versions, m.from)
+	}
+	return append(versions, LatestVersion)
+}
+
+// migrateTree upgrades the configuration tree to LatestVersion step by step,
+// and returns the warnings of what has been changed.
+func migrateTree(tree yaml.MapSlice) (yaml.MapSlice, []string, error) {
+	v, ok := getKey(tree, "version")
+	if !ok || v == nil {
+		return nil, nil, fmt.Errorf("Please configure the version, supported versions are: %s", strings.Join(SupportedVersions(), ", "))
+	}
+	current, ok := scalarText(v)
+	if !ok {
+		return nil, nil, fmt.Errorf("Invalid version: %v", v)
+	}
+
+	var warnings []string
+	for _, m := range migrations {
+		if m.from != current {
+			continue
+		}
+		w, err := m.migrate(tree)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Fail to migrate configure from %s to %s: %v", m.from, m.to, err)
+		}
+		warnings = append(warnings, fmt.Sprintf("The configure version %s is deprecated, migrated to %s", m.from, m.to))
+		warnings = append(warnings, w...)
+		tree = setKey(tree, "version", m.to)
+		current = m.to
+	}
+
+	if current != LatestVersion {
+		return nil, nil, fmt.Errorf("Unsupported version: %s, supported versions are: %s", current, strings.Join(SupportedVersions(), ", "))
+	}
+	return tree, warnings, nil
+}
+
+// MigrateFile upgrades the configuration file to LatestVersion and returns the
+// migrated content. Includes and environment variables are kept as they are,
+// while the comments are dropped.
+func MigrateFile(filename string) ([]byte, []string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, err := parseTree(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	tree, warnings, err := migrateTree(tree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	b, err := yaml.Marshal(resolveScalars(tree, reflect.TypeOf(YAMLConfig{})))
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, warnings, nil
+}
+
+// In v1rc1 the columns are mapped to vid, rank and props by their order, and
+// the ranking of edges is switched on by withRanking. v1rc2 gives every column
+// an explicit index and configures the ranking column by rank.index.
+func migrateV1rc1(tree yaml.MapSlice) ([]string, error) {
+	v, _ := getKey(tree, "files")
+	if v == nil {
+		return nil, nil
+	}
+	files, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("files must be a list")
+	}
+
+	var warnings []string
+	for i := range files {
+		file, ok := files[i].(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		s, _ := getKey(file, "schema")
+		schema, ok := s.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		prefix := fmt.Sprintf("files[%d].schema", i)
+		if e, ok := getKey(schema, "edge"); ok {
+			if edge, ok := e.(yaml.MapSlice); ok {
+				edge, w := migrateV1rc1Edge(edge, prefix+".edge")
+				schema = setKey(schema, "edge", edge)
+				warnings = append(warnings, w...)
+			}
+		}
+		if vt, ok := getKey(schema, "vertex"); ok {
+			if vertex, ok := vt.(yaml.MapSlice); ok {
+				vertex, w := migrateV1rc1Vertex(vertex, prefix+".vertex")
+				schema = setKey(schema, "vertex", vertex)
+				warnings = append(warnings, w...)
+			}
+		}
+		files[i] = setKey(file, "schema", schema)
+	}
+	return warnings, nil
+}
+
+func migrateV1rc1Edge(edge yaml.MapSlice, prefix string) (yaml.MapSlice, []string) {
+	var warnings []string
+	edge, w := setDefaultIndex(edge, "srcVID", 0, prefix)
+	warnings = append(warnings, w...)
+	edge, w = setDefaultIndex(edge, "dstVID", 1, prefix)
+	warnings = append(warnings, w...)
+
+	start := 2
+	v, _ := getKey(edge, "withRanking")
+	if s, ok := v.(scalar); ok {
+		v = s.value
+	}
+	withRanking, isBool := v.(bool)
+	if _, ok := getKey(edge, "rank"); ok || withRanking {
+		edge, w = setDefaultIndex(edge, "rank", 2, prefix)
+		warnings = append(warnings, w...)
+		start++
+	}
+	if isBool {
+		_, edge = popKey(edge, "withRanking")
+		warnings = append(warnings, fmt.Sprintf("%s.withRanking is replaced by %s.rank", prefix, prefix))
+	}
+
+	edge, w = setPropIndexes(edge, start, prefix)
+	return edge, append(warnings, w...)
+}
+
+func migrateV1rc1Vertex(vertex yaml.MapSlice, prefix string) (yaml.MapSlice, []string) {
+	vertex, warnings := setDefaultIndex(vertex, "vid", 0, prefix)
+	v, _ := getKey(vertex, "tags")
+	tags, ok := v.([]interface{})
+	if !ok {
+		return vertex, warnings
+	}
+	start := 1
+	for i := range tags {
+		tag, ok := tags[i].(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		var w []string
+		tag, w = setPropIndexes(tag, start, fmt.Sprintf("%s.tags[%d]", prefix, i))
+		warnings = append(warnings, w...)
+		if props, ok := getKey(tag, "props"); ok {
+			if l, ok := props.([]interface{}); ok {
+				start += len(l)
+			}
+		}
+		tags[i] = tag
+	}
+	return vertex, warnings
+}
+
+// setDefaultIndex gives the column key of the node the index implied by the
+// column order of v1rc1.
+func setDefaultIndex(node yaml.MapSlice, key string, index int, prefix string) (yaml.MapSlice, []string) {
+	v, _ := getKey(node, key)
+	col, ok := v.(yaml.MapSlice)
+	if v != nil && !ok {
+		return node, nil
+	}
+	if _, ok := getKey(col, "index"); ok {
+		return node, nil
+	}
+	col = setKey(col, "index", index)
+	return setKey(node, key, col), []string{fmt.Sprintf("%s.%s.index is set to %d by the column order", prefix, key, index)}
+}
+
+func setPropIndexes(node yaml.MapSlice, start int, prefix string) (yaml.MapSlice, []string) {
+	v, _ := getKey(node, "props")
+	props, ok := v.([]interface{})
+	if !ok {
+		return node, nil
+	}
+	var warnings []string
+	for i := range props {
+		prop, ok := props[i].(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		if _, ok := getKey(prop, "index"); !ok {
+			props[i] = setKey(prop, "index", start+i)
+			warnings = append(warnings, fmt.Sprintf("%s.props[%d].index is set to %d by the column order", prefix, i, start+i))
+		}
+	}
+	return node, warnings
+}
+
+func getKey(m yaml.MapSlice, key string) (interface{}, bool) {
+	for i := range m {
+		if m[i].Key == key {
+			return m[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+func setKey(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i := range m {
+		if m[i].Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The actions of Prop.OnNull
+const (
+	ON_NULL_DEFAULT  = "default"
+	ON_NULL_SKIP_ROW = "skip-row"
+	ON_NULL_ERROR    = "error"
+)
+
+// ErrSkipRow is returned when a row is skipped because of a null value, or a
+// missing key of a lookup, it is not a failure of the row.
+var ErrSkipRow = errors.New("The row is skipped")
+
+// isNull reports whether the cell is a null value of the prop. The empty cells
+// are null values of all the types but string by default.
+func (p *Prop) isNull(cell string) bool {
+	nullValues := p.NullValues
+	if nullValues == nil && !p.IsStringType() {
+		nullValues = []string{""}
+	}
+	for _, v := range nullValues {
+		if cell == v {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceNull replaces the null cell by the default value, or returns an
+// error according to onNull.
+func (p *Prop) replaceNull(cell string) (string, error) {
+	if !p.isNull(cell) {
+		return cell, nil
+	}
+	switch p.onNull() {
+	case ON_NULL_DEFAULT:
+		return *p.Default, nil
+	case ON_NULL_SKIP_ROW:
+		return "", fmt.Errorf("prop %s: %w", *p.Name, ErrSkipRow)
+	default:
+		return "", fmt.Errorf("prop %s: null value %q", *p.Name, cell)
+	}
+}
+
+// onNull returns the action for the null values, which is default if the
+// default value is configured, or error otherwise.
+func (p *Prop) onNull() string {
+	if p.OnNull != nil {
+		return *p.OnNull
+	}
+	if p.Default != nil {
+		return ON_NULL_DEFAULT
+	}
+	return ON_NULL_ERROR
+}
+
+// validateNull checks the action for the null values, the unset fields are
+// left as they are so that the prop is still known to configure nothing.
+func (p *Prop) validateNull(prefix string) error {
+	if p.OnNull == nil {
+		return nil
+	}
+	*p.OnNull = strings.ToLower(*p.OnNull)
+	switch *p.OnNull {
+	case ON_NULL_DEFAULT:
+		if p.Default == nil {
+			return fmt.Errorf("Please configure %s.default for %s.onNull: %s", prefix, prefix, *p.OnNull)
+		}
+	case ON_NULL_SKIP_ROW, ON_NULL_ERROR:
+	default:
+		return fmt.Errorf("Invalid %s.onNull: %s, expected %s, %s or %s", prefix, *p.OnNull, ON_NULL_DEFAULT, ON_NULL_SKIP_ROW, ON_NULL_ERROR)
+	}
+	return nil
+}
+
+// configuresNull reports whether the null values of the prop are configured.
+func (p *Prop) configuresNull() bool {
+	return p.Default != nil || p.NullValues != nil || p.OnNull != nil
+}
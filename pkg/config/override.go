@@ -0,0 +1,329 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvOverridePrefix prefixes the environment variables which override
+// configuration keys, e.g. NEBULA_IMPORTER__CLIENTSETTINGS__SPACE=prod or
+// NEBULA_IMPORTER__FILES__0__BATCHSIZE=256.
+const EnvOverridePrefix = "NEBULA_IMPORTER__"
+
+// Override sets the value of a configuration key, e.g. clientSettings.space=prod.
+type Override struct {
+	Key    string
+	Value  string
+	Source string
+	path   []interface{}
+	parsed interface{}
+}
+
+// ParseOverride parses key=value, source tells where the override comes from in error messages.
+func ParseOverride(s string, source string) (*Override, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return nil, fmt.Errorf("Invalid override %s, it must be in the form of key=value", source)
+	}
+	o := &Override{
+		Key:    strings.TrimSpace(kv[0]),
+		Value:  kv[1],
+		Source: source,
+	}
+	segments, err := splitKey(o.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if o.path, err = canonicalPath(segments); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if o.parsed, err = o.value(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// EnvOverrides collects the overrides in environment variables of the form
+// key=value, in which the key is prefixed with EnvOverridePrefix.
+func EnvOverrides(environ []string) ([]*Override, error) {
+	var overrides []*Override
+	for _, env := range environ {
+		if !strings.HasPrefix(env, EnvOverridePrefix) {
+			continue
+		}
+		kv := strings.SplitN(env, "=", 2)
+		key := strings.Replace(strings.TrimPrefix(kv[0], EnvOverridePrefix), "__", ".", -1)
+		value := ""
+		if len(kv) > 1 {
+			value = kv[1]
+		}
+		o, err := ParseOverride(fmt.Sprintf("%s=%s", key, value), fmt.Sprintf("environment variable %s", kv[0]))
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// splitKey splits files[0].csv.withHeader or files.0.csv.withHeader into
+// string keys and int indexes.
+func splitKey(key string) ([]interface{}, error) {
+	var segments []interface{}
+	for _, part := range strings.Split(key, ".") {
+		name := part
+		var indexes []int
+		for strings.HasSuffix(name, "]") {
+			i := strings.LastIndex(name, "[")
+			if i < 0 {
+				return nil, fmt.Errorf("Invalid key: %s", key)
+			}
+			idx, err := strconv.Atoi(name[i+1 : len(name)-1])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("Invalid index in key: %s", key)
+			}
+			indexes = append([]int{idx}, indexes...)
+			name = name[:i]
+		}
+		if name == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("Invalid key: %s", key)
+		}
+		if name != "" {
+			if idx, err := strconv.Atoi(name); err == nil && idx >= 0 {
+				segments = append(segments, idx)
+			} else {
+				segments = append(segments, name)
+			}
+		}
+		for _, idx := range indexes {
+			segments = append(segments, idx)
+		}
+	}
+	return segments, nil
+}
+
+// canonicalPath checks the key segments against YAMLConfig and corrects the
+// case of keys, e.g. CLIENTSETTINGS.SPACE to clientSettings.space.
+func canonicalPath(segments []interface{}) ([]interface{}, error) {
+	t := reflect.TypeOf(YAMLConfig{})
+	path := make([]interface{}, 0, len(segments))
+	for _, seg := range segments {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			name := fmt.Sprint(seg)
+			field, ok := findYAMLField(t, name)
+			if !ok {
+				return nil, fmt.Errorf("Unknown key %s in %s", name, renderPath(path))
+			}
+			path = append(path, yamlName(field))
+			t = field.Type
+		case reflect.Slice:
+			idx, ok := seg.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s is a list, the key must be an index instead of %v", renderPath(path), seg)
+			}
+			path = append(path, idx)
+			t = t.Elem()
+		case reflect.Map:
+			path = append(path, fmt.Sprint(seg))
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s is a %s value, it has no key %v", renderPath(path), t.Kind(), seg)
+		}
+	}
+	return path, nil
+}
+
+// typeOfPath returns the type of value at path in YAMLConfig.
+func typeOfPath(path []interface{}) reflect.Type {
+	t := reflect.TypeOf(YAMLConfig{})
+	for _, seg := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, _ := findYAMLField(t, fmt.Sprint(seg))
+			t = f.Type
+		default:
+			t = t.Elem()
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func renderPath(path []interface{}) string {
+	var builder strings.Builder
+	for _, seg := range path {
+		if idx, ok := seg.(int); ok {
+			builder.WriteString(fmt.Sprintf("[%d]", idx))
+		} else {
+			if builder.Len() > 0 {
+				builder.WriteString(".")
+			}
+			builder.WriteString(fmt.Sprint(seg))
+		}
+	}
+	return builder.String()
+}
+
+// Path returns the canonical key of the override, e.g. files[0].batchSize.
+func (o *Override) Path() string {
+	return renderPath(o.path)
+}
+
+// value converts the override value to the type of its key.
+func (o *Override) value() (interface{}, error) {
+	t := typeOfPath(o.path)
+	switch t.Kind() {
+	case reflect.String:
+		return o.Value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.Atoi(strings.TrimSpace(o.Value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s must be an integer, but got %q", o.Source, o.Path(), o.Value)
+		}
+		return v, nil
+	case reflect.Bool:
+		v, err := strconv.ParseBool(strings.TrimSpace(o.Value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s must be true or false, but got %q", o.Source, o.Path(), o.Value)
+		}
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(strings.TrimSpace(o.Value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s must be a number, but got %q", o.Source, o.Path(), o.Value)
+		}
+		return v, nil
+	default:
+		// Mappings and lists are given in YAML flow style, e.g. {withHeader: true}
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(o.Value), &v); err != nil {
+			return nil, fmt.Errorf("%s: invalid value of %s: %v", o.Source, o.Path(), err)
+		}
+		return toTree(v), nil
+	}
+}
+
+// toTree converts the maps decoded by yaml into yaml.MapSlice.
+func toTree(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[interface{}]interface{}:
+		var m yaml.MapSlice
+		for k, val := range n {
+			m = append(m, yaml.MapItem{Key: k, Value: toTree(val)})
+		}
+		return m
+	case []interface{}:
+		for i := range n {
+			n[i] = toTree(n[i])
+		}
+		return n
+	default:
+		return n
+	}
+}
+
+func (o *Override) apply(tree yaml.MapSlice) (yaml.MapSlice, error) {
+	node, err := setPath(tree, o.path, o.parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", o.Source, err)
+	}
+	if m, ok := node.(yaml.MapSlice); ok {
+		return m, nil
+	}
+	return tree, nil
+}
+
+// setPath sets the value at path of the node, creating the missing mappings.
+// An index equal to the length of a list appends to the list.
+func setPath(node interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch seg := path[0].(type) {
+	case int:
+		list, ok := node.([]interface{})
+		if !ok && node != nil {
+			return nil, fmt.Errorf("%s is not a list", renderPath(path[:1]))
+		}
+		if seg > len(list) {
+			return nil, fmt.Errorf("Index %d is out of range, the list has %d items", seg, len(list))
+		}
+		if seg == len(list) {
+			list = append(list, nil)
+		}
+		v, err := setPath(list[seg], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[seg] = v
+		return list, nil
+	default:
+		m, ok := node.(yaml.MapSlice)
+		if !ok && node != nil {
+			return nil, fmt.Errorf("%v is not a mapping", seg)
+		}
+		for i := range m {
+			if fmt.Sprint(m[i].Key) == fmt.Sprint(seg) {
+				v, err := setPath(m[i].Value, path[1:], value)
+				if err != nil {
+					return nil, err
+				}
+				m[i].Value = v
+				return m, nil
+			}
+		}
+		v, err := setPath(nil, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return append(m, yaml.MapItem{Key: seg, Value: v}), nil
+	}
+}
+
+// annotate points the validation error at the overrides of the keys it mentions.
+func annotate(err error, overrides []*Override) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	var sources []string
+	for _, o := range overrides {
+		if mentions(msg, o.Path()) {
+			sources = append(sources, fmt.Sprintf("%s is set by %s", o.Path(), o.Source))
+		}
+	}
+	if len(sources) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s (%s)", msg, strings.Join(sources, ", "))
+}
+
+// mentions tells whether the message mentions the configure item of the path,
+// or an item inside it. files[1] is not mentioned by files[10], so the path
+// must be followed by the end of a key.
+func mentions(msg, path string) bool {
+	for i := strings.Index(msg, path); i >= 0; {
+		if end := i + len(path); end == len(msg) || strings.ContainsRune(".[:, ", rune(msg[end])) {
+			return true
+		}
+		next := strings.Index(msg[i+1:], path)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var jsonUnknownPattern = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// unknownKeys reports the keys of the tree which are not in the configure
+// structs, with their paths and the lines they are written in.
+func unknownKeys(tree yaml.MapSlice, lines keyLines) []error {
+	keys := unknownTreeKeys(tree, reflect.TypeOf(YAMLConfig{}), nil)
+	errs := make([]error, 0, len(keys))
+	for _, k := range keys {
+		msg := "Unknown key: " + k.path
+		if k.suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %s?", k.suggestion)
+		}
+		errs = append(errs, errors.New(msg+lines.at(k.path)))
+	}
+	return errs
+}
+
+// unknownKey is a key of the configuration file which is not in the configure
+// structs, and the suggested key which differs from it only in case, if any.
+type unknownKey struct {
+	path       string
+	key        string
+	suggestion string
+}
+
+// unknownTreeKeys reports the keys of the tree which are not in the configure
+// struct of type t.
+func unknownTreeKeys(node interface{}, t reflect.Type, path []interface{}) []unknownKey {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var keys []unknownKey
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for _, item := range n {
+			key := fmt.Sprint(item.Key)
+			p := append(path[:len(path):len(path)], key)
+			switch t.Kind() {
+			case reflect.Struct:
+				f, ok := findYAMLField(t, key)
+				if !ok {
+					keys = append(keys, unknownKey{path: renderPath(p), key: key})
+				} else if name := yamlName(f); name != key {
+					keys = append(keys, unknownKey{path: renderPath(p), key: key, suggestion: name})
+				} else {
+					keys = append(keys, unknownTreeKeys(item.Value, f.Type, p)...)
+				}
+			case reflect.Map:
+				keys = append(keys, unknownTreeKeys(item.Value, t.Elem(), p)...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i := range n {
+				keys = append(keys, unknownTreeKeys(n[i], t.Elem(), append(path[:len(path):len(path)], i))...)
+			}
+		}
+	}
+	return keys
+}
+
+func unknownKeysError(errs []error) error {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// DecodeJSON decodes the configuration in JSON. The unknown keys are rejected
+// with their paths and lines if strict is true.
+func DecodeJSON(data []byte, conf *YAMLConfig, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(conf)
+	if err == nil {
+		return nil
+	}
+	m := jsonUnknownPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	if path, offset, ok := jsonUnknownKey(data, reflect.TypeOf(*conf)); ok {
+		return fmt.Errorf("Unknown key: %s (line %d)", path, bytes.Count(data[:offset], []byte("\n"))+1)
+	}
+	return fmt.Errorf("Unknown key: %s", m[1])
+}
+
+// jsonUnknownKey walks the tokens of the JSON document, and returns the path
+// and the offset of the first key which is not in the configure struct of type
+// t. Only the keys are matched, so a value like the key isn't taken for it.
+func jsonUnknownKey(data []byte, t reflect.Type) (string, int64, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var walk func(t reflect.Type, path []interface{}) (string, int64, bool)
+	walk = func(t reflect.Type, path []interface{}) (string, int64, bool) {
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return "", 0, false
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				token, err := decoder.Token()
+				if err != nil {
+					return "", 0, false
+				}
+				key, _ := token.(string)
+				p := append(path[:len(path):len(path)], key)
+				var elem reflect.Type
+				if t != nil && t.Kind() == reflect.Struct {
+					f, ok := findJSONField(t, key)
+					if !ok {
+						return renderPath(p), decoder.InputOffset(), true
+					}
+					elem = f.Type
+				} else if t != nil && t.Kind() == reflect.Map {
+					elem = t.Elem()
+				}
+				if path, offset, ok := walk(elem, p); ok {
+					return path, offset, true
+				}
+			}
+			decoder.Token()
+		case json.Delim('['):
+			var elem reflect.Type
+			if t != nil && t.Kind() == reflect.Slice {
+				elem = t.Elem()
+			}
+			for i := 0; decoder.More(); i++ {
+				if path, offset, ok := walk(elem, append(path[:len(path):len(path)], i)); ok {
+					return path, offset, true
+				}
+			}
+			decoder.Token()
+		}
+		return "", 0, false
+	}
+	return walk(t, nil)
+}
+
+// findJSONField finds the field of struct type t by its JSON key, case
+// insensitively like encoding/json.
+func findJSONField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
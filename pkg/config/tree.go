@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The configuration is preprocessed as an ordered generic tree of
+// yaml.MapSlice, []interface{} and scalars before it is decoded into YAMLConfig.
+
+func parseTree(content []byte) (yaml.MapSlice, error) {
+	var tree yaml.MapSlice
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return nil, err
+	}
+	var text textTree
+	if err := yaml.Unmarshal(content, &text); err != nil {
+		return nil, err
+	}
+	return keepText(tree, text.node).(yaml.MapSlice), nil
+}
+
+func decodeTree(tree yaml.MapSlice, conf *YAMLConfig) error {
+	b, err := yaml.Marshal(resolveScalars(tree, reflect.TypeOf(*conf)))
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, conf)
+}
+
+// scalar is a plain scalar resolved to a bool, number or null whose text is
+// not its canonical form, e.g. password: 012345 or name: no. The text is kept
+// so that it is decoded into a string field as it is written.
+type scalar struct {
+	text  string
+	value interface{}
+}
+
+func (s scalar) MarshalYAML() (interface{}, error) {
+	return s.value, nil
+}
+
+// textTree decodes the YAML document with every scalar as its text.
+type textTree struct {
+	node interface{}
+}
+
+func (t *textTree) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[interface{}]*textTree
+	if err := unmarshal(&m); err == nil {
+		t.node = m
+		return nil
+	}
+	var l []*textTree
+	if err := unmarshal(&l); err == nil {
+		t.node = l
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err == nil {
+		t.node = s
+	}
+	return nil
+}
+
+// keepText wraps the non-string scalars of the tree whose text differs from
+// the canonical form of their values.
+func keepText(node interface{}, text interface{}) interface{} {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		m, _ := text.(map[interface{}]*textTree)
+		for i := range n {
+			if t, ok := m[n[i].Key]; ok && t != nil {
+				n[i].Value = keepText(n[i].Value, t.node)
+			}
+		}
+		return n
+	case []interface{}:
+		l, _ := text.([]*textTree)
+		for i := range n {
+			if i < len(l) && l[i] != nil {
+				n[i] = keepText(n[i], l[i].node)
+			}
+		}
+		return n
+	case string, nil:
+		return n
+	default:
+		s, ok := text.(string)
+		if !ok || s == "" || s == fmt.Sprint(n) {
+			return n
+		}
+		if f, ok := n.(float64); ok && strconv.FormatFloat(f, 'f', -1, 64) == s {
+			return n
+		}
+		return scalar{text: s, value: n}
+	}
+}
+
+// resolveScalars replaces the kept scalars by their texts where the configure
+// struct of type t expects strings, and by their values elsewhere.
+func resolveScalars(node interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for i := range n {
+			var elem reflect.Type
+			if t != nil && t.Kind() == reflect.Struct {
+				if f, ok := findYAMLField(t, fmt.Sprint(n[i].Key)); ok && yamlName(f) == fmt.Sprint(n[i].Key) {
+					elem = f.Type
+				}
+			} else if t != nil && t.Kind() == reflect.Map {
+				elem = t.Elem()
+			}
+			n[i].Value = resolveScalars(n[i].Value, elem)
+		}
+		return n
+	case []interface{}:
+		var elem reflect.Type
+		if t != nil && t.Kind() == reflect.Slice {
+			elem = t.Elem()
+		}
+		for i := range n {
+			n[i] = resolveScalars(n[i], elem)
+		}
+		return n
+	case scalar:
+		if t != nil && t.Kind() == reflect.String {
+			return n.text
+		}
+		return n.value
+	default:
+		return n
+	}
+}
+
+// scalarText returns the string value or the kept text of the scalar.
+func scalarText(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case scalar:
+		return s.text, true
+	}
+	return "", false
+}
+
+// walkStrings replaces every string scalar in the tree by the result of f,
+// which is given the path of the scalar like files[0].path.
+func walkStrings(node interface{}, path string, f func(path string, s string) (interface{}, error)) (interface{}, error) {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for i := range n {
+			v, err := walkStrings(n[i].Value, joinPath(path, fmt.Sprint(n[i].Key)), f)
+			if err != nil {
+				return nil, err
+			}
+			n[i].Value = v
+		}
+		return n, nil
+	case []interface{}:
+		for i := range n {
+			v, err := walkStrings(n[i], fmt.Sprintf("%s[%d]", path, i), f)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = v
+		}
+		return n, nil
+	case string:
+		return f(path, n)
+	default:
+		return n, nil
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return fmt.Sprintf("%s.%s", path, key)
+}
+
+// typedScalar resolves the string to the int, float or bool it represents, if
+// the representation is canonical, so that substituted numbers keep their type.
+func typedScalar(s string) interface{} {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch t := v.(type) {
+	case int:
+		if strconv.Itoa(t) == s {
+			return t
+		}
+	case float64:
+		if strconv.FormatFloat(t, 'f', -1, 64) == s {
+			return t
+		}
+	case bool:
+		if strconv.FormatBool(t) == s {
+			return t
+		}
+	}
+	return s
+}
+
+// yamlName returns the key of field in the config file.
+func yamlName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if tag == "" {
+		return strings.ToLower(field.Name)
+	}
+	return tag
+}
+
+// findYAMLField finds the field of struct type t by its key in the config
+// file, case insensitively.
+func findYAMLField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("yaml") == "-" || f.PkgPath != "" {
+			continue
+		}
+		if strings.EqualFold(yamlName(f), name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
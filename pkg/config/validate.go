@@ -0,0 +1,316 @@
+package config
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// validateSampleRows is the number of rows read from each data file to check
+// the column indexes against.
+const validateSampleRows = 100
+
+// Validate parses the configuration file like ParseWithOptions, but instead of
+// stopping at the first error, it reports all the problems it finds. The
+// returned configuration is nil if the file can't be decoded at all.
+func Validate(filename string, opts ParseOptions) (*YAMLConfig, []error) {
+	lines := make(keyLines)
+	tree, err := loadTree(filename, nil, lines)
+	if err != nil {
+		return nil, []error{err}
+	}
+	unknown := unknownKeys(tree, lines)
+	if tree, _, err = migrateTree(tree); err != nil {
+		return nil, []error{err}
+	}
+	for _, o := range opts.Overrides {
+		if tree, err = o.apply(tree); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	var errs []error
+	if !opts.AllowUnknownKeys {
+		errs = unknown
+	}
+
+	var conf YAMLConfig
+	if err = decodeTree(tree, &conf); err != nil {
+		if _, ok := err.(*yaml.TypeError); !ok {
+			return nil, append(errs, err)
+		}
+		// The line numbers of the error are those of the merged tree instead
+		// of the files, so the invalid values are found by their paths
+		t := reflect.TypeOf(conf)
+		for _, v := range invalidValues(resolveScalars(tree, t), t, nil) {
+			err := fmt.Errorf("Invalid type of %s: %s%s", v.path, v.msg, lines.at(v.path))
+			errs = append(errs, annotate(err, opts.Overrides))
+		}
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, append(errs, err)
+	}
+	for _, err := range conf.validateAndReset(dir, true) {
+		errs = append(errs, annotate(err, opts.Overrides))
+	}
+
+	for i, f := range conf.Files {
+		if f != nil {
+			errs = append(errs, f.checkColumns(fmt.Sprintf("files[%d]", i))...)
+		}
+	}
+	return &conf, errs
+}
+
+// column is a configure item mapped to a column of the data file.
+type column struct {
+	path  string
+	index int
+}
+
+// checkColumns reports the columns mapped by more than one prop, and the
+// indexes beyond the number of columns of the data file.
+func (f *File) checkColumns(prefix string) []error {
+	var errs []error
+	var columns []column
+	if len(f.Schemas) > 0 {
+		for i, s := range f.Schemas {
+			c, e := s.columns(fmt.Sprintf("%s.schemas[%d]", prefix, i))
+			columns = append(columns, c...)
+			errs = append(errs, e...)
+		}
+	} else {
+		// The columns are defined by the header
+		if f.CSV != nil && f.CSV.WithHeader != nil && *f.CSV.WithHeader && !f.CSV.IsSkipHeader() {
+			return nil
+		}
+		columns, errs = f.Schema.columns(fmt.Sprintf("%s.schema", prefix))
+	}
+	if len(columns) == 0 {
+		return errs
+	}
+
+	for _, path := range f.Paths {
+		n, err := f.sampleColumns(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Fail to read %s: %v", path, err))
+			continue
+		}
+		if n < 0 {
+			continue
+		}
+		for _, c := range columns {
+			if c.index >= n {
+				errs = append(errs, fmt.Errorf("%s.index %d is out of range, %s has only %d columns", c.path, c.index, path, n))
+			}
+		}
+	}
+	return errs
+}
+
+// columns returns the columns mapped by the schema, and the overlaps of them.
+func (s *Schema) columns(prefix string) ([]column, []error) {
+	if s == nil || s.Type == nil {
+		return nil, nil
+	}
+
+	var errs []error
+	var columns []column
+	switch strings.ToLower(*s.Type) {
+	case "vertex":
+		v := s.Vertex
+		if v == nil {
+			return nil, nil
+		}
+		prefix = fmt.Sprintf("%s.vertex", prefix)
+		if v.VID != nil && v.VID.Index != nil {
+			columns = append(columns, column{fmt.Sprintf("%s.vid", prefix), *v.VID.Index})
+		}
+		var props []column
+		for i, tag := range v.Tags {
+			if tag != nil {
+				props = append(props, propColumns(tag.Props, fmt.Sprintf("%s.tags[%d]", prefix, i))...)
+				if tag.When != nil && tag.When.Index != nil {
+					columns = append(columns, column{fmt.Sprintf("%s.tags[%d].when", prefix, i), *tag.When.Index})
+				}
+			}
+		}
+		errs = append(errs, overlaps(props)...)
+		columns = append(columns, props...)
+	case "edge":
+		e := s.Edge
+		if e == nil {
+			return nil, nil
+		}
+		prefix = fmt.Sprintf("%s.edge", prefix)
+		var ids []column
+		if e.SrcVID != nil && e.SrcVID.Index != nil {
+			ids = append(ids, column{fmt.Sprintf("%s.srcVID", prefix), *e.SrcVID.Index})
+		}
+		if e.DstVID != nil && e.DstVID.Index != nil {
+			ids = append(ids, column{fmt.Sprintf("%s.dstVID", prefix), *e.DstVID.Index})
+		}
+		if e.Rank != nil && e.Rank.Index != nil {
+			ids = append(ids, column{fmt.Sprintf("%s.rank", prefix), *e.Rank.Index})
+		}
+		if e.TypeColumn != nil && e.TypeColumn.Index != nil {
+			ids = append(ids, column{fmt.Sprintf("%s.typeColumn", prefix), *e.TypeColumn.Index})
+		}
+		props := propColumns(e.Props, prefix)
+		errs = append(errs, overlaps(ids)...)
+		errs = append(errs, overlaps(props)...)
+		// The rows are of one edge type, so only the props of the same type
+		// can't overlap
+		for i, t := range e.Types {
+			if t != nil {
+				typeProps := propColumns(t.Props, fmt.Sprintf("%s.types[%d]", prefix, i))
+				errs = append(errs, overlaps(typeProps)...)
+				props = append(props, typeProps...)
+			}
+		}
+		columns = append(append(columns, ids...), props...)
+	}
+	return columns, errs
+}
+
+func propColumns(props []*Prop, prefix string) []column {
+	var columns []column
+	for i, p := range props {
+		if p != nil && p.Index != nil {
+			columns = append(columns, column{fmt.Sprintf("%s.props[%d]", prefix, i), *p.Index})
+		}
+	}
+	return columns
+}
+
+func overlaps(columns []column) []error {
+	byIndex := make(map[int][]string)
+	for _, c := range columns {
+		byIndex[c.index] = append(byIndex[c.index], c.path)
+	}
+	var indexes []int
+	for idx, paths := range byIndex {
+		if len(paths) > 1 {
+			indexes = append(indexes, idx)
+		}
+	}
+	sort.Ints(indexes)
+	var errs []error
+	for _, idx := range indexes {
+		errs = append(errs, fmt.Errorf("%s are mapped to the same column %d", strings.Join(byIndex[idx], ", "), idx))
+	}
+	return errs
+}
+
+// sampleColumns returns the least number of columns in the first rows of the
+// data file, or -1 if the file is empty.
+func (f *File) sampleColumns(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(bufio.NewReader(file))
+	r.FieldsPerRecord = -1
+	if f.CSV != nil && f.CSV.Delimiter != nil {
+		if d := []rune(*f.CSV.Delimiter); len(d) > 0 {
+			r.Comma = d[0]
+		}
+	}
+	withLabel := f.CSV != nil && f.CSV.WithLabel != nil && *f.CSV.WithLabel
+
+	n := -1
+	for i := 0; i < validateSampleRows; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		cols := len(record)
+		if withLabel {
+			cols--
+		}
+		if n < 0 || cols < n {
+			n = cols
+		}
+	}
+	return n, nil
+}
+
+// Dump marshals the configuration into YAML, the password is redacted and only
+// the items which are set are kept.
+func (config *YAMLConfig) Dump() ([]byte, error) {
+	tree, _ := dumpTree(reflect.ValueOf(config)).(yaml.MapSlice)
+	if v, ok := getKey(tree, "clientSettings"); ok {
+		if c, ok := getKey(v.(yaml.MapSlice), "connection"); ok {
+			if p, ok := getKey(c.(yaml.MapSlice), "password"); ok {
+				setKey(c.(yaml.MapSlice), "password", Redact(fmt.Sprint(p)))
+			}
+		}
+	}
+	return yaml.Marshal(tree)
+}
+
+// dumpTree converts the configure struct into a tree of the items which are
+// set. The nil pointers, lists and maps, and the structs left empty are
+// omitted, while the empty lists which are set, like nullValues: [], are kept.
+func dumpTree(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return dumpTree(v.Elem())
+	case reflect.Struct:
+		var m yaml.MapSlice
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("yaml") == "-" {
+				continue
+			}
+			if item := dumpTree(v.Field(i)); item != nil {
+				m = append(m, yaml.MapItem{Key: yamlName(f), Value: item})
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			l[i] = dumpTree(v.Index(i))
+		}
+		return l
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+		m := make(yaml.MapSlice, 0, len(keys))
+		for _, k := range keys {
+			m = append(m, yaml.MapItem{Key: k.Interface(), Value: dumpTree(v.MapIndex(k))})
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
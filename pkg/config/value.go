@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+)
+
+// The literals of bool props by default, compared case-insensitively
+var (
+	defaultTrueValues  = []string{"true", "1"}
+	defaultFalseValues = []string{"false", "0"}
+)
+
+// parseValue parses the cell of the bool, float, double or timestamp prop, and
+// formats it as an nGQL literal.
+func (p *Prop) parseValue(cell string) (string, error) {
+	c := strings.TrimSpace(cell)
+	switch strings.ToLower(*p.Type) {
+	case "bool":
+		return p.parseBool(c)
+	case "float", "double":
+		return p.parseFloat(c)
+	case "timestamp":
+		return p.parseTimestamp(c)
+	default:
+		if p.IsDateTimestampType() {
+			return p.parseDateTimestamp(c)
+		}
+		return cell, nil
+	}
+}
+
+func (p *Prop) parseBool(cell string) (string, error) {
+	trueValues, falseValues := p.TrueValues, p.FalseValues
+	if trueValues == nil {
+		trueValues = defaultTrueValues
+	}
+	if falseValues == nil {
+		falseValues = defaultFalseValues
+	}
+	for _, v := range trueValues {
+		if strings.EqualFold(cell, v) {
+			return "true", nil
+		}
+	}
+	for _, v := range falseValues {
+		if strings.EqualFold(cell, v) {
+			return "false", nil
+		}
+	}
+	return "", fmt.Errorf("prop %s: invalid bool %q, expected one of %s or %s", *p.Name, cell,
+		strings.Join(trueValues, ","), strings.Join(falseValues, ","))
+}
+
+func (p *Prop) parseFloat(cell string) (string, error) {
+	c := cell
+	if p.DecimalSeparator != nil && *p.DecimalSeparator != "." {
+		if strings.Contains(c, ".") {
+			return "", fmt.Errorf("prop %s: invalid %s %q with the decimal separator %q", *p.Name, *p.Type, cell, *p.DecimalSeparator)
+		}
+		c = strings.Replace(c, *p.DecimalSeparator, ".", 1)
+	}
+	f, err := strconv.ParseFloat(c, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("prop %s: invalid %s %q", *p.Name, *p.Type, cell)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+// parseTimestamp parses the cell by the layouts, which are tried in order, and
+// as the seconds since the epoch unless the layouts are of another epoch unit.
+func (p *Prop) parseTimestamp(cell string) (string, error) {
+	layouts := p.Layouts
+	if layouts == nil {
+		layouts = base.DateLayouts
+	}
+	if !hasEpochLayout(layouts) {
+		layouts = append([]string{LAYOUT_EPOCH_SECONDS}, layouts...)
+	}
+	if v, ok := p.parseTime(cell, layouts); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("prop %s: invalid timestamp %q, expected one of the layouts: %s", *p.Name, cell, strings.Join(layouts, ", "))
+}
+
+// parseDateTimestamp parses the cell by the layout of the type, e.g.
+// date-timestamp:2006-01-02, and then by the fallback layouts.
+func (p *Prop) parseDateTimestamp(cell string) (string, error) {
+	layouts := append([]string{strings.SplitN(*p.Type, ":", 2)[1]}, p.Layouts...)
+	if v, ok := p.parseTime(cell, layouts); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("prop %s: invalid date-timestamp %q, expected one of the layouts: %s", *p.Name, cell, strings.Join(layouts, ", "))
+}
+
+// The named layouts, besides the Go layouts
+const (
+	LAYOUT_EPOCH_SECONDS = "epoch-seconds"
+	LAYOUT_EPOCH_MILLIS  = "epoch-millis"
+)
+
+var namedLayouts = map[string]string{
+	"date":        "2006-01-02",
+	"datetime":    "2006-01-02 15:04:05",
+	"iso8601":     "2006-01-02T15:04:05Z07:00",
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+}
+
+func hasEpochLayout(layouts []string) bool {
+	for _, layout := range layouts {
+		switch strings.ToLower(layout) {
+		case LAYOUT_EPOCH_SECONDS, LAYOUT_EPOCH_MILLIS:
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime parses the cell by the first matching layout, and formats it as
+// the seconds since the epoch. The times without zones are in the time zone of
+// the prop, UTC by default.
+func (p *Prop) parseTime(cell string, layouts []string) (string, bool) {
+	loc := p.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range layouts {
+		switch name := strings.ToLower(layout); name {
+		case LAYOUT_EPOCH_SECONDS, LAYOUT_EPOCH_MILLIS:
+			n, err := strconv.ParseInt(cell, 10, 64)
+			if err != nil {
+				continue
+			}
+			if name == LAYOUT_EPOCH_MILLIS {
+				n /= 1000
+			}
+			return strconv.FormatInt(n, 10), true
+		default:
+			if l, ok := namedLayouts[name]; ok {
+				layout = l
+			}
+			if t, err := time.ParseInLocation(layout, cell, loc); err == nil {
+				return strconv.FormatInt(t.Unix(), 10), true
+			}
+		}
+	}
+	return "", false
+}
+
+// loadLocation loads the time zone, either a name of the IANA time zone
+// database, e.g. Asia/Shanghai, or an offset, e.g. +08:00.
+func loadLocation(zone string) (*time.Location, error) {
+	if t, err := time.Parse("-07:00", zone); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(zone, offset), nil
+	}
+	return time.LoadLocation(zone)
+}
+
+// validateParsing checks the options of parsing the values.
+func (p *Prop) validateParsing(prefix string) error {
+	if p.TimeZone != nil {
+		loc, err := loadLocation(*p.TimeZone)
+		if err != nil {
+			return fmt.Errorf("Invalid %s.timeZone: %v", prefix, err)
+		}
+		p.location = loc
+	}
+	if p.DecimalSeparator != nil && len([]rune(*p.DecimalSeparator)) != 1 {
+		return fmt.Errorf("Invalid %s.decimalSeparator: %q, expected a character", prefix, *p.DecimalSeparator)
+	}
+	for _, v := range p.TrueValues {
+		for _, f := range p.FalseValues {
+			if strings.EqualFold(v, f) {
+				return fmt.Errorf("%s.trueValues and %s.falseValues both contain %q", prefix, prefix, v)
+			}
+		}
+	}
+	return nil
+}
+
+// configuresParsing reports whether the options of parsing the values of the
+// prop are configured.
+func (p *Prop) configuresParsing() bool {
+	return p.TrueValues != nil || p.FalseValues != nil || p.DecimalSeparator != nil || p.Layouts != nil || p.TimeZone != nil
+}
@@ -12,11 +12,16 @@ import (
 type ErrWriter struct {
 	writer    *csv.Writer
 	csvConfig *config.CSVConfig
+	// written are the keys of the source rows which have been written
+	written map[string]bool
+	logger  *logger.Logger
 }
 
-func NewErrDataWriter(config *config.CSVConfig) *ErrWriter {
+func NewErrDataWriter(config *config.CSVConfig, l *logger.Logger) *ErrWriter {
 	return &ErrWriter{
 		csvConfig: config,
+		written:   make(map[string]bool),
+		logger:    l,
 	}
 }
 
@@ -30,9 +35,20 @@ func (w *ErrWriter) Init(f *os.File) {
 
 func (w *ErrWriter) Write(data []base.Data) {
 	if len(data) == 0 {
-		logger.Info("Empty error data")
+		w.logger.Info("Empty error data")
 	}
 	for _, d := range data {
+		if d.Source != nil {
+			// The elements of the same row are written as the row once, even if
+			// they fail in different batches
+			if d.SourceKey != "" {
+				if w.written[d.SourceKey] {
+					continue
+				}
+				w.written[d.SourceKey] = true
+			}
+			d.Record = d.Source
+		}
 		if *w.csvConfig.WithLabel {
 			var record []string
 			switch d.Type {
@@ -41,7 +57,7 @@ func (w *ErrWriter) Write(data []base.Data) {
 			case base.DELETE:
 				record = append(record, "-")
 			default:
-				logger.Fatalf("Error data type: %s", d.Type)
+				w.logger.Fatalf("Error data type: %s", d.Type)
 			}
 			record = append(record, d.Record...)
 			w.writer.Write(record)
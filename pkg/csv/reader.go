@@ -13,6 +13,7 @@ import (
 
 type CSVReader struct {
 	CSVConfig *config.CSVConfig
+	Logger    *logger.Logger
 	reader    *csv.Reader
 	lineNum   uint64
 }
@@ -23,7 +24,7 @@ func (r *CSVReader) InitReader(file *os.File) {
 		d := []rune(*r.CSVConfig.Delimiter)
 		if len(d) > 0 {
 			r.reader.Comma = d[0]
-			logger.Infof("The delimiter of %s is %#U", file.Name(), r.reader.Comma)
+			r.Logger.Infof("The delimiter of %s is %#U", file.Name(), r.reader.Comma)
 		}
 	}
 }
@@ -12,26 +12,30 @@ import (
 
 type Handler struct {
 	statsCh chan<- base.Stats
+	logger  *logger.Logger
 }
 
-func New(statsCh chan<- base.Stats) *Handler {
+func New(statsCh chan<- base.Stats, l *logger.Logger) *Handler {
 	h := Handler{
 		statsCh: statsCh,
+		logger:  l,
 	}
 
 	return &h
 }
 
-func (w *Handler) Init(file *config.File, concurrency int) (chan base.ErrData, error) {
+// Init starts to write the failed rows of the schema of the file, and sends the
+// FILEDONE stats once all the clients are done with the schema.
+func (w *Handler) Init(file *config.File, schema *config.Schema, concurrency int) (chan base.ErrData, error) {
 	var dataWriter DataWriter
 	switch strings.ToLower(*file.Type) {
 	case "csv":
-		dataWriter = csv.NewErrDataWriter(file.CSV)
+		dataWriter = csv.NewErrDataWriter(file.CSV, w.logger)
 	default:
 		return nil, fmt.Errorf("Wrong file type: %s", *file.Type)
 	}
 
-	dataFile := base.MustCreateFile(*file.FailDataPath)
+	dataFile := base.MustCreateFile(file.MappingFailDataPath(schema))
 	errCh := make(chan base.ErrData)
 
 	go func() {
@@ -48,16 +52,16 @@ func (w *Handler) Init(file *config.File, concurrency int) (chan base.ErrData, e
 				}
 			} else {
 				dataWriter.Write(rawErr.Data)
-				logger.Error(rawErr.Error.Error())
-				w.statsCh <- base.NewFailureStats(len(rawErr.Data))
+				w.logger.Error(rawErr.Error.Error())
+				w.statsCh <- base.NewFailureStats(len(rawErr.Data), *file.Space)
 			}
 		}
 
 		dataWriter.Flush()
 		if dataWriter.Error() != nil {
-			logger.Error(dataWriter.Error())
+			w.logger.Error(dataWriter.Error())
 		}
-		w.statsCh <- base.NewFileDoneStats(*file.Path)
+		w.statsCh <- base.NewFileDoneStats(*file.Path, file.MappingName(schema))
 	}()
 
 	return errCh, nil
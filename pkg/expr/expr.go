@@ -0,0 +1,498 @@
+// Package expr evaluates the expressions which derive values from the columns
+// of a row, e.g. concat(tenant, ":", $0) or if(amount > 0, "in", "out").
+//
+// The values are strings, integers, floats, booleans and null. The columns are
+// strings referred by their indexes, e.g. $0, or by their names in the header,
+// e.g. amount, or `unit price` if the name isn't an identifier. Arithmetic and
+// comparison operators convert strings to numbers when needed.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value is the value of an expression, one of string, int64, float64, bool
+// and nil.
+type Value interface{}
+
+// Func is a function which can be called in expressions.
+type Func func(args []Value) (Value, error)
+
+// Env is the environment the expressions are evaluated in.
+type Env struct {
+	// Columns are the indexes of the columns by their names
+	Columns map[string]int
+	// Funcs are the functions besides the builtin ones
+	Funcs map[string]Func
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses the expression.
+func Compile(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid expression %q: %v", src, err)
+	}
+	p := parser{tokens: tokens}
+	root, err := p.parseBinary(0)
+	if err == nil && p.peek().kind != tokEOF {
+		err = unexpected(p.peek(), "the end")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid expression %q: %v", src, err)
+	}
+	if err := checkCalls(root); err != nil {
+		return nil, fmt.Errorf("Invalid expression %q: %v", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (x *Expr) String() string {
+	return x.src
+}
+
+// Names returns the names of the columns referred by the expression.
+func (x *Expr) Names() []string {
+	var names []string
+	walk(x.root, func(n node) {
+		if c, ok := n.(*column); ok && c.index < 0 {
+			names = append(names, c.name)
+		}
+	})
+	return names
+}
+
+// Calls returns the names of the functions called by the expression.
+func (x *Expr) Calls() []string {
+	var names []string
+	walk(x.root, func(n node) {
+		if c, ok := n.(*call); ok {
+			names = append(names, c.name)
+		}
+	})
+	return names
+}
+
+// CheckFuncs checks that the expression calls only the builtin functions and
+// the functions of names, which are registered in the environment.
+func (x *Expr) CheckFuncs(names ...string) error {
+	for _, name := range x.Calls() {
+		if _, ok := builtins[name]; ok {
+			continue
+		}
+		known := false
+		for _, n := range names {
+			if n == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("Unknown function %s", name)
+		}
+	}
+	return nil
+}
+
+// Args returns the arguments of the calls of the function, the arguments
+// which aren't literals are nil.
+func (x *Expr) Args(name string) [][]Value {
+	var args [][]Value
+	walk(x.root, func(n node) {
+		c, ok := n.(*call)
+		if !ok || c.name != name {
+			return
+		}
+		values := make([]Value, len(c.args))
+		for i, a := range c.args {
+			if l, ok := a.(*literal); ok {
+				values[i] = l.value
+			}
+		}
+		args = append(args, values)
+	})
+	return args
+}
+
+// Eval evaluates the expression with the row.
+func (x *Expr) Eval(record []string, env *Env) (Value, error) {
+	v, err := x.root.eval(record, env)
+	if err != nil {
+		return nil, fmt.Errorf("Fail to evaluate %q: %w", x.src, err)
+	}
+	return v, nil
+}
+
+// EvalString evaluates the expression and formats the value as a string.
+func (x *Expr) EvalString(record []string, env *Env) (string, error) {
+	v, err := x.Eval(record, env)
+	if err != nil {
+		return "", err
+	}
+	return ToString(v), nil
+}
+
+// ToString formats the value, null is the empty string.
+func ToString(v Value) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// Truthy reports whether the value is true as a condition. Null, false, zero,
+// the empty string and the strings "false" and "0" are false.
+func Truthy(v Value) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case int64:
+		return t != 0
+	case float64:
+		return t != 0
+	case string:
+		return t != "" && t != "0" && !strings.EqualFold(t, "false")
+	default:
+		return true
+	}
+}
+
+type node interface {
+	eval(record []string, env *Env) (Value, error)
+	children() []node
+}
+
+func walk(n node, f func(node)) {
+	f(n)
+	for _, c := range n.children() {
+		walk(c, f)
+	}
+}
+
+type literal struct {
+	value Value
+}
+
+func (l *literal) eval([]string, *Env) (Value, error) {
+	return l.value, nil
+}
+
+func (l *literal) children() []node {
+	return nil
+}
+
+type column struct {
+	index int
+	name  string
+}
+
+func (c *column) eval(record []string, env *Env) (Value, error) {
+	idx := c.index
+	if idx < 0 {
+		i, ok := -1, false
+		if env != nil {
+			i, ok = env.Columns[c.name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %s", c.name)
+		}
+		idx = i
+	}
+	if idx >= len(record) {
+		return nil, fmt.Errorf("Column %d out range %d of record(%v)", idx, len(record), record)
+	}
+	return record[idx], nil
+}
+
+func (c *column) children() []node {
+	return nil
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u *unary) eval(record []string, env *Env) (Value, error) {
+	v, err := u.operand.eval(record, env)
+	if err != nil {
+		return nil, err
+	}
+	if u.op == "!" {
+		return !Truthy(v), nil
+	}
+	n, err := toNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := n.(int64); ok {
+		return -i, nil
+	}
+	return -n.(float64), nil
+}
+
+func (u *unary) children() []node {
+	return []node{u.operand}
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b *binary) eval(record []string, env *Env) (Value, error) {
+	l, err := b.left.eval(record, env)
+	if err != nil {
+		return nil, err
+	}
+	// && and || only evaluate the right operand when needed
+	switch b.op {
+	case "&&":
+		if !Truthy(l) {
+			return false, nil
+		}
+		r, err := b.right.eval(record, env)
+		return Truthy(r), err
+	case "||":
+		if Truthy(l) {
+			return true, nil
+		}
+		r, err := b.right.eval(record, env)
+		return Truthy(r), err
+	}
+
+	r, err := b.right.eval(record, env)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return compare(b.op, l, r)
+	default:
+		return arithmetic(b.op, l, r)
+	}
+}
+
+func (b *binary) children() []node {
+	return []node{b.left, b.right}
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func (c *call) eval(record []string, env *Env) (Value, error) {
+	// if only evaluates the chosen branch
+	if c.name == "if" {
+		cond, err := c.args[0].eval(record, env)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(cond) {
+			return c.args[1].eval(record, env)
+		}
+		if len(c.args) > 2 {
+			return c.args[2].eval(record, env)
+		}
+		return nil, nil
+	}
+
+	args := make([]Value, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(record, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if b, ok := builtins[c.name]; ok {
+		return b.f(args)
+	}
+	if env != nil {
+		if f, ok := env.Funcs[c.name]; ok {
+			v, err := f(args)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", c.name, err)
+			}
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown function %s", c.name)
+}
+
+func (c *call) children() []node {
+	return c.args
+}
+
+// checkCalls checks the numbers of the arguments of the builtin functions.
+func checkCalls(root node) error {
+	var err error
+	walk(root, func(n node) {
+		c, ok := n.(*call)
+		if !ok || err != nil {
+			return
+		}
+		b, ok := builtins[c.name]
+		if !ok {
+			return
+		}
+		if len(c.args) < b.minArgs || (b.maxArgs >= 0 && len(c.args) > b.maxArgs) {
+			err = fmt.Errorf("Wrong number of arguments of %s: %d", c.name, len(c.args))
+		}
+	})
+	return err
+}
+
+// toNumber converts the value to int64 or float64.
+func toNumber(v Value) (Value, error) {
+	switch t := v.(type) {
+	case int64, float64:
+		return t, nil
+	case string:
+		s := strings.TrimSpace(t)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("%q is not a number", t)
+	default:
+		return nil, fmt.Errorf("%v is not a number", ToString(v))
+	}
+}
+
+func toFloat(v Value) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func arithmetic(op string, l, r Value) (Value, error) {
+	ln, err := toNumber(l)
+	if err != nil {
+		return nil, err
+	}
+	rn, err := toNumber(r)
+	if err != nil {
+		return nil, err
+	}
+	li, lok := ln.(int64)
+	ri, rok := rn.(int64)
+	if lok && rok {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("Division by zero")
+			}
+			if op == "%" {
+				return li % ri, nil
+			}
+			if li%ri == 0 {
+				return li / ri, nil
+			}
+		}
+	}
+	lf, rf := toFloat(ln), toFloat(rn)
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("Division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, fmt.Errorf("Division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, fmt.Errorf("Unknown operator %s", op)
+}
+
+// compare compares the values as numbers if one of them is a number and the
+// other can be converted to a number, or as strings otherwise.
+func compare(op string, l, r Value) (Value, error) {
+	var c int
+	if lb, ok := l.(bool); ok {
+		if rb, ok := r.(bool); ok {
+			if op != "==" && op != "!=" {
+				return nil, fmt.Errorf("Booleans can't be compared by %s", op)
+			}
+			return (lb == rb) == (op == "=="), nil
+		}
+	}
+	if isNumber(l) || isNumber(r) {
+		ln, lerr := toNumber(l)
+		rn, rerr := toNumber(r)
+		if lerr == nil && rerr == nil {
+			lf, rf := toFloat(ln), toFloat(rn)
+			switch {
+			case lf < rf:
+				c = -1
+			case lf > rf:
+				c = 1
+			}
+			return result(op, c), nil
+		}
+	}
+	return result(op, strings.Compare(ToString(l), ToString(r))), nil
+}
+
+func isNumber(v Value) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func result(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	record := []string{"42", "Acme", "Tom Smith", "", "12.5", "deleted"}
+	env := &Env{
+		Columns: map[string]int{"id": 0, "tenant": 1, "unit price": 4, "status": 5},
+		Funcs: map[string]Func{
+			"double": func(args []Value) (Value, error) {
+				return arithmetic("*", args[0], int64(2))
+			},
+		},
+	}
+	for _, c := range []struct {
+		src      string
+		expected string
+	}{
+		{`concat(tenant, ":", $0)`, "Acme:42"},
+		{`lower(tenant)`, "acme"},
+		{`upper(substr($2, 0, 3))`, "TOM"},
+		{`substr($2, 4)`, "Smith"},
+		{`substr($2, 20, 2)`, ""},
+		{`coalesce($3, tenant)`, "Acme"},
+		{`if(status == "deleted", "gone", "kept")`, "gone"},
+		{`if(id > 100, "big")`, ""},
+		{`id * 2 + 1`, "85"},
+		{`id / 4`, "10.5"},
+		{`id / 2`, "21"},
+		{`id % 5`, "2"},
+		{"`unit price` * 2", "25"},
+		{`-id + 2`, "-40"},
+		{`(1 + 2) * 3`, "9"},
+		{`status != "deleted" && id > 0`, "false"},
+		{`status == "active" || !($3 == "")`, "false"},
+		{`id > 9`, "true"},
+		{`"10" > "9"`, "false"},
+		{`double(id)`, "84"},
+		{`concat("a\"b", 'c')`, `a"bc`},
+	} {
+		x, err := Compile(c.src)
+		if err != nil {
+			t.Errorf("Fail to compile %s: %v", c.src, err)
+			continue
+		}
+		v, err := x.EvalString(record, env)
+		if err != nil {
+			t.Errorf("Fail to evaluate %s: %v", c.src, err)
+			continue
+		}
+		if v != c.expected {
+			t.Errorf("%s is %q, expected %q", c.src, v, c.expected)
+		}
+	}
+
+	x, err := Compile(`substr($0, 1, $1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := x.EvalString([]string{"42", "9223372036854775807"}, nil); err != nil || v != "2" {
+		t.Errorf("The length of substr should not overflow: %q, %v", v, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, c := range []struct {
+		src string
+		err string
+	}{
+		{`concat(a`, "Expect ',' or ')' at the end"},
+		{`1 +`, "Expect a value at the end"},
+		{`"abc`, "Unterminated string"},
+		{`a = b`, "Unexpected '='"},
+		{`lower(a, b)`, "Wrong number of arguments of lower"},
+		{`$`, "Invalid column index"},
+		{`a b`, "Expect the end at 2"},
+	} {
+		if _, err := Compile(c.src); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Error of %s should contain %q: %v", c.src, c.err, err)
+		}
+	}
+
+	for _, c := range []struct {
+		src string
+		err string
+	}{
+		{`name`, "Unknown column name"},
+		{`$5`, "Column 5 out range 1"},
+		{`$0 + 1`, `"abc" is not a number`},
+		{`1 / 0`, "Division by zero"},
+		{`f(1)`, "Unknown function f"},
+	} {
+		x, err := Compile(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := x.Eval([]string{"abc"}, nil); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Error of %s should contain %q: %v", c.src, c.err, err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	x, err := Compile(`concat(tenant, $0, lookup("countries", code))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(x.Names(), ","); names != "tenant,code" {
+		t.Errorf("Error names: %s", names)
+	}
+	if calls := strings.Join(x.Calls(), ","); calls != "concat,lookup" {
+		t.Errorf("Error calls: %s", calls)
+	}
+	if args := fmt.Sprint(x.Args("lookup")); args != "[[countries <nil>]]" {
+		t.Errorf("Error args of lookup: %s", args)
+	}
+}
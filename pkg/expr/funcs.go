@@ -0,0 +1,88 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+type builtin struct {
+	minArgs int
+	// maxArgs is -1 if the number of arguments is not limited
+	maxArgs int
+	f       Func
+}
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"concat":   {1, -1, concat},
+		"substr":   {2, 3, substr},
+		"lower":    {1, 1, stringFunc(strings.ToLower)},
+		"upper":    {1, 1, stringFunc(strings.ToUpper)},
+		"trim":     {1, 1, stringFunc(strings.TrimSpace)},
+		"coalesce": {1, -1, coalesce},
+		// if is evaluated lazily by call.eval
+		"if": {2, 3, nil},
+	}
+}
+
+func concat(args []Value) (Value, error) {
+	var b strings.Builder
+	for _, a := range args {
+		b.WriteString(ToString(a))
+	}
+	return b.String(), nil
+}
+
+// substr returns length characters from start, which counts from 0, or till
+// the end if length is not given.
+func substr(args []Value) (Value, error) {
+	s := []rune(ToString(args[0]))
+	start, err := toInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || start > int64(len(s)) {
+		start = int64(len(s))
+	}
+	end := int64(len(s))
+	if len(args) > 2 {
+		length, err := toInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		// compares with the rest instead of start+length, which overflows
+		if length >= 0 && length < end-start {
+			end = start + length
+		}
+	}
+	return string(s[start:end]), nil
+}
+
+func toInt(v Value) (int64, error) {
+	n, err := toNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if i, ok := n.(int64); ok {
+		return i, nil
+	}
+	return 0, fmt.Errorf("%v is not an integer", ToString(v))
+}
+
+func stringFunc(f func(string) string) Func {
+	return func(args []Value) (Value, error) {
+		return f(ToString(args[0])), nil
+	}
+}
+
+// coalesce returns the first argument which is neither null nor empty.
+func coalesce(args []Value) (Value, error) {
+	for _, a := range args {
+		if ToString(a) != "" {
+			return a, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,257 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokColumn
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits the source of an expression into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[i:j]), i})
+			i = j
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != c; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+					switch runes[j] {
+					case 'n':
+						b.WriteRune('\n')
+					case 't':
+						b.WriteRune('\t')
+					case 'r':
+						b.WriteRune('\r')
+					default:
+						b.WriteRune(runes[j])
+					}
+					continue
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("Unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokString, b.String(), i})
+			i = j + 1
+		case c == '`':
+			j := i + 1
+			for j < len(runes) && runes[j] != '`' {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("Unterminated column name at %d", i)
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[i+1 : j]), i})
+			i = j + 1
+		case c == '$':
+			j := i + 1
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("Invalid column index at %d", i)
+			}
+			tokens = append(tokens, token{tokColumn, string(runes[i+1 : j]), i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || runes[j] == '.' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[i:j]), i})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%<>!(),", op) && len(op) == 1 {
+				return nil, fmt.Errorf("Unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+// precedences of the binary operators, the higher binds tighter.
+var precedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return unexpected(t, op)
+	}
+	return nil
+}
+
+func unexpected(t token, expected string) error {
+	if t.kind == tokEOF {
+		return fmt.Errorf("Expect %s at the end", expected)
+	}
+	return fmt.Errorf("Expect %s at %d, but got %q", expected, t.pos, t.text)
+}
+
+// parseBinary parses the binary operations whose operators bind tighter than
+// minPrec.
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := precedences[t.text]
+		if t.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if t := p.peek(); t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %s at %d", t.text, t.pos)
+		}
+		return &literal{value: f}, nil
+	case tokString:
+		return &literal{value: t.text}, nil
+	case tokColumn:
+		i, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid column index $%s at %d", t.text, t.pos)
+		}
+		return &column{index: i}, nil
+	case tokIdent:
+		if next := p.peek(); next.kind == tokOp && next.text == "(" {
+			return p.parseCall(t)
+		}
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		return &column{index: -1, name: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			n, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+	}
+	return nil, unexpected(t, "a value")
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next()
+	c := &call{name: name.text}
+	if t := p.peek(); t.kind == tokOp && t.text == ")" {
+		p.next()
+		return c, nil
+	}
+	for {
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+		t := p.next()
+		if t.kind == tokOp && t.text == ")" {
+			return c, nil
+		}
+		if t.kind != tokOp || t.text != "," {
+			return nil, unexpected(t, "',' or ')'")
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package gen
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+)
+
+// FileSpec is a data file to import as the vertices of a tag or the edges of
+// an edge type.
+type FileSpec struct {
+	Kind string // vertex or edge
+	Name string // tag or edge type name
+	Path string
+}
+
+type Options struct {
+	Files      []FileSpec
+	WithHeader bool
+	Delimiter  string
+	SampleRows int
+	// Schema is the props of the space, the props are inferred from the data
+	// files if it is nil
+	Schema     *SpaceSchema
+	Space      string
+	Address    string
+	User       string
+	OutputPath string
+}
+
+// PasswordEnv is the environment variable the password is read from, both by
+// the generated configure and by querying the schema.
+const PasswordEnv = "NEBULA_PASSWORD"
+
+var (
+	vidColumns = []string{"vid", "id", ":vid"}
+	srcColumns = []string{"src", "src_id", "srcid", "src_vid", "from", "from_id", "source", ":src_vid"}
+	dstColumns = []string{"dst", "dst_id", "dstid", "dst_vid", "to", "to_id", "target", ":dst_vid"}
+	rankColumn = []string{"rank", "ranking", ":rank"}
+)
+
+// sample is the header and the first rows of a data file.
+type sample struct {
+	header []string
+	rows   [][]string
+}
+
+func (s *sample) column(i int) []string {
+	var values []string
+	for _, r := range s.rows {
+		if i < len(r) {
+			values = append(values, r[i])
+		}
+	}
+	return values
+}
+
+// Generate generates the configuration to import the files, and returns the
+// warnings of the columns which can't be mapped.
+func Generate(opts Options) (*config.YAMLConfig, []string, error) {
+	if opts.SampleRows <= 0 {
+		return nil, nil, fmt.Errorf("Invalid number of sample rows %d, it must be positive", opts.SampleRows)
+	}
+	version := config.LatestVersion
+	space := opts.Space
+	user := opts.User
+	if user == "" {
+		user = "user"
+	}
+	address := opts.Address
+	if address == "" {
+		address = "127.0.0.1:3699"
+	}
+	passwordEnv := PasswordEnv
+	conf := &config.YAMLConfig{
+		Version: &version,
+		NebulaClientSettings: &config.NebulaClientSettings{
+			Space: &space,
+			Connection: &config.NebulaClientConnection{
+				User:            &user,
+				PasswordFromEnv: &passwordEnv,
+				Address:         &address,
+			},
+		},
+	}
+
+	var warnings []string
+	for i, spec := range opts.Files {
+		s, err := readSample(spec.Path, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		f, w, err := generateFile(spec, s, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, msg := range w {
+			warnings = append(warnings, fmt.Sprintf("files[%d](%s): %s", i, spec.Path, msg))
+		}
+		conf.Files = append(conf.Files, f)
+	}
+	return conf, warnings, nil
+}
+
+func readSample(path string, opts Options) (*sample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(bufio.NewReader(file))
+	r.FieldsPerRecord = -1
+	if d := []rune(opts.Delimiter); len(d) > 0 {
+		r.Comma = d[0]
+	}
+	s := &sample{}
+	for len(s.rows) < opts.SampleRows {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Fail to read %s: %v", path, err)
+		}
+		if opts.WithHeader && s.header == nil {
+			for i := range record {
+				s.header = append(s.header, strings.TrimSpace(strings.TrimPrefix(record[i], "\ufeff")))
+			}
+			continue
+		}
+		s.rows = append(s.rows, record)
+	}
+	if s.header == nil {
+		n := 0
+		for _, r := range s.rows {
+			if len(r) > n {
+				n = len(r)
+			}
+		}
+		for i := 0; i < n; i++ {
+			s.header = append(s.header, fmt.Sprintf("col%d", i))
+		}
+	}
+	return s, nil
+}
+
+func generateFile(spec FileSpec, s *sample, opts Options) (*config.File, []string, error) {
+	path := spec.Path
+	if opts.OutputPath != "" {
+		if abs, err := filepath.Abs(spec.Path); err == nil {
+			if rel, err := filepath.Rel(filepath.Dir(opts.OutputPath), abs); err == nil {
+				path = rel
+			}
+		}
+	}
+	typ := "csv"
+	withHeader, withLabel := opts.WithHeader, false
+	f := &config.File{
+		Path: &path,
+		Type: &typ,
+		CSV: &config.CSVConfig{
+			WithHeader: &withHeader,
+			WithLabel:  &withLabel,
+		},
+	}
+	if opts.WithHeader {
+		// The header of the data file isn't in the format of the importer, it
+		// is skipped and the columns are mapped by their indexes
+		skipHeader := true
+		f.CSV.SkipHeader = &skipHeader
+	}
+	if opts.Delimiter != "" && opts.Delimiter != "," {
+		d := opts.Delimiter
+		f.CSV.Delimiter = &d
+	}
+
+	kind := strings.ToLower(spec.Kind)
+	name := spec.Name
+	f.Schema = &config.Schema{Type: &kind}
+	used := make(map[int]bool)
+	var warnings []string
+
+	var fields []Field
+	hasSchema := false
+	if opts.Schema != nil {
+		schemaFields := opts.Schema.Tags
+		if kind == "edge" {
+			schemaFields = opts.Schema.Edges
+		}
+		fs, ok := schemaFields[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s %s doesn't exist in space %s", kind, name, opts.Space)
+		}
+		fields, hasSchema = fs, true
+	}
+
+	switch kind {
+	case "vertex":
+		vid := s.vid(findColumn(s.header, append(vidColumns, name+"_id", name+"id"), 0), used)
+		f.Schema.Vertex = &config.Vertex{
+			VID:  vid,
+			Tags: []*config.Tag{{Name: &name}},
+		}
+		props, w := s.props(fields, hasSchema, used, !opts.WithHeader)
+		f.Schema.Vertex.Tags[0].Props = props
+		warnings = w
+	case "edge":
+		src := s.vid(findColumn(s.header, srcColumns, 0), used)
+		dst := s.vid(findColumn(s.header, dstColumns, 1), used)
+		f.Schema.Edge = &config.Edge{
+			Name:   &name,
+			SrcVID: src,
+			DstVID: dst,
+		}
+		if i := findColumn(s.header, rankColumn, -1); i >= 0 && !used[i] {
+			used[i] = true
+			f.Schema.Edge.Rank = &config.Rank{Index: &i}
+		}
+		props, w := s.props(fields, hasSchema, used, !opts.WithHeader)
+		f.Schema.Edge.Props = props
+		warnings = w
+	default:
+		return nil, nil, fmt.Errorf("Invalid kind of %s: %s, only vertex and edge are supported", spec.Path, spec.Kind)
+	}
+	if !opts.WithHeader && !hasSchema {
+		warnings = append(warnings, "the props are named after the column indexes, rename them to the props of the space")
+	}
+	return f, warnings, nil
+}
+
+// findColumn returns the index of the first column named one of names, or def
+// if there is none.
+func findColumn(header []string, names []string, def int) int {
+	for _, n := range names {
+		for i, h := range header {
+			if strings.EqualFold(h, n) {
+				return i
+			}
+		}
+	}
+	return def
+}
+
+// vid maps the column to a VID, which is hashed unless all the values are
+// integers.
+func (s *sample) vid(i int, used map[int]bool) *config.VID {
+	used[i] = true
+	vid := &config.VID{Index: &i}
+	if base.InferType(s.column(i)) != "int" {
+		hash := "hash"
+		vid.Function = &hash
+	}
+	return vid
+}
+
+// props maps the unused columns to props. With the schema of the space, the
+// columns are matched to the fields by name, or by order if the columns are
+// not named.
+func (s *sample) props(fields []Field, hasSchema bool, used map[int]bool, byOrder bool) ([]*config.Prop, []string) {
+	var unused []int
+	for i := range s.header {
+		if !used[i] {
+			unused = append(unused, i)
+		}
+	}
+
+	var props []*config.Prop
+	var warnings []string
+	if !hasSchema {
+		for _, i := range unused {
+			name, typ, idx := s.header[i], base.InferType(s.column(i)), i
+			props = append(props, &config.Prop{Name: &name, Type: &typ, Index: &idx})
+		}
+		return props, warnings
+	}
+
+	matched := make(map[int]bool)
+	for j, field := range fields {
+		idx := -1
+		if byOrder {
+			if j < len(unused) {
+				idx = unused[j]
+			}
+		} else {
+			idx = findColumn(s.header, []string{field.Name}, -1)
+		}
+		if idx < 0 || used[idx] {
+			warnings = append(warnings, fmt.Sprintf("prop %s has no column", field.Name))
+			continue
+		}
+		matched[idx] = true
+		name, typ := field.Name, field.Type
+		// The dates are converted to timestamps by the importer
+		if inferred := base.InferType(s.column(idx)); typ == "timestamp" && strings.HasPrefix(inferred, "date-timestamp") {
+			typ = inferred
+		} else if !base.IsValidType(typ) {
+			warnings = append(warnings, fmt.Sprintf("prop %s of type %s is imported as string", field.Name, typ))
+			typ = "string"
+		}
+		i := idx
+		props = append(props, &config.Prop{Name: &name, Type: &typ, Index: &i})
+	}
+	for _, i := range unused {
+		if !matched[i] {
+			warnings = append(warnings, fmt.Sprintf("column %d(%s) is not mapped to any prop", i, s.header[i]))
+		}
+	}
+	return props, warnings
+}
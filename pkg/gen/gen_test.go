@@ -0,0 +1,63 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	person := filepath.Join(dir, "person.csv")
+	content := "id,name,age,birthday,vip\nalice,Alice,20,2000-01-02,true\nbob,Bob,,1999-12-31,false\n"
+	if err := ioutil.WriteFile(person, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	follow := filepath.Join(dir, "follow.csv")
+	if err := ioutil.WriteFile(follow, []byte("degree,to,from\n0.5,1,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, _, err := Generate(Options{
+		Files:      []FileSpec{{"vertex", "person", person}, {"edge", "follow", follow}},
+		WithHeader: true,
+		SampleRows: 10,
+		Space:      "test",
+		Schema: &SpaceSchema{
+			Tags:  map[string][]Field{"person": {{"name", "string"}, {"age", "int"}, {"birthday", "timestamp"}, {"vip", "bool"}}},
+			Edges: map[string][]Field{"follow": {{"degree", "double"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if csv := conf.Files[0].CSV; !*csv.WithHeader || !csv.IsSkipHeader() {
+		t.Error("The header should be skipped instead of imported")
+	}
+	vertex := conf.Files[0].Schema.Vertex
+	if *vertex.VID.Index != 0 || vertex.VID.Function == nil || *vertex.VID.Function != "hash" {
+		t.Errorf("Error vid: %s", vertex.String())
+	}
+	props := vertex.Tags[0].Props
+	if len(props) != 4 || *props[1].Type != "int" || *props[2].Type != "date-timestamp:2006-01-02" || *props[3].Index != 4 {
+		t.Errorf("Error props: %s", vertex.String())
+	}
+
+	edge := conf.Files[1].Schema.Edge
+	if *edge.SrcVID.Index != 2 || *edge.DstVID.Index != 1 || edge.SrcVID.Function != nil || len(edge.Props) != 1 || *edge.Props[0].Index != 0 {
+		t.Errorf("Error edge: %s", edge.String())
+	}
+
+	if _, _, err = Generate(Options{Files: []FileSpec{{"vertex", "user", person}}, WithHeader: true, SampleRows: 10, Schema: &SpaceSchema{}}); err == nil {
+		t.Error("Tag user doesn't exist in the space")
+	}
+	if _, _, err = Generate(Options{Files: []FileSpec{{"vertex", "person", person}}, WithHeader: true}); err == nil {
+		t.Error("The number of sample rows should be positive")
+	}
+}
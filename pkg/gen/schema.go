@@ -0,0 +1,112 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	nebula "github.com/vesoft-inc/nebula-go"
+	graph "github.com/vesoft-inc/nebula-go/nebula/graph"
+	"github.com/vesoft-inc/nebula-importer/pkg/client"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// Field is a prop of a tag or an edge type in the space.
+type Field struct {
+	Name string
+	Type string
+}
+
+// SpaceSchema is the props of the tags and edge types in a space.
+type SpaceSchema struct {
+	Tags  map[string][]Field
+	Edges map[string][]Field
+}
+
+// QuerySchema queries the props of the tags and edge types in the space.
+func QuerySchema(address, user, password, space string) (*SpaceSchema, error) {
+	addr := strings.Split(address, ",")[0]
+	conn, err := client.NewNebulaConnection(addr, user, password)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Disconnect()
+
+	if _, err := execute(conn, fmt.Sprintf("USE %s;", space)); err != nil {
+		return nil, err
+	}
+
+	schema := &SpaceSchema{
+		Tags:  make(map[string][]Field),
+		Edges: make(map[string][]Field),
+	}
+	for _, kind := range []struct {
+		show     string
+		describe string
+		fields   map[string][]Field
+	}{
+		{"SHOW TAGS;", "DESCRIBE TAG `%s`;", schema.Tags},
+		{"SHOW EDGES;", "DESCRIBE EDGE `%s`;", schema.Edges},
+	} {
+		rows, err := execute(conn, kind.show)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			name := row["Name"]
+			fields, err := execute(conn, fmt.Sprintf(kind.describe, name))
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				kind.fields[name] = append(kind.fields[name], Field{Name: f["Field"], Type: strings.ToLower(f["Type"])})
+			}
+		}
+	}
+	logger.Infof("Schema of space %s: %d tags, %d edges", space, len(schema.Tags), len(schema.Edges))
+	return schema, nil
+}
+
+// execute runs the statement and returns the rows by column names.
+func execute(conn *nebula.GraphClient, stmt string) ([]map[string]string, error) {
+	resp, err := conn.Execute(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetErrorCode() != graph.ErrorCode_SUCCEEDED {
+		return nil, fmt.Errorf("Fail to execute: %s, ErrMsg: %s, ErrCode: %v", stmt, resp.GetErrorMsg(), resp.GetErrorCode())
+	}
+
+	names := resp.GetColumnNames()
+	var rows []map[string]string
+	for _, r := range resp.GetRows() {
+		row := make(map[string]string)
+		for i, c := range r.GetColumns() {
+			if i < len(names) {
+				row[string(names[i])] = columnString(c)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func columnString(c *graph.ColumnValue) string {
+	switch {
+	case c.IsSetStr():
+		return string(c.GetStr())
+	case c.IsSetInteger():
+		return fmt.Sprint(c.GetInteger())
+	case c.IsSetId():
+		return fmt.Sprint(c.GetId())
+	case c.IsSetBoolVal():
+		return fmt.Sprint(c.GetBoolVal())
+	case c.IsSetDoublePrecision():
+		return fmt.Sprint(c.GetDoublePrecision())
+	case c.IsSetSinglePrecision():
+		return fmt.Sprint(c.GetSinglePrecision())
+	case c.IsSetTimestamp():
+		return fmt.Sprint(c.GetTimestamp())
+	default:
+		return ""
+	}
+}
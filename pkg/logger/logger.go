@@ -11,90 +11,125 @@ import (
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
 )
 
-var logger *log.Logger = log.New(os.Stdout, "", log.LstdFlags)
+// Logger writes leveled logs with the caller position. Each import task owns
+// one, so that the logs of concurrent tasks do not interleave.
+type Logger struct {
+	logger *log.Logger
+	file   *os.File
+}
 
-func Init(path string) {
+func New(w io.Writer) *Logger {
+	return &Logger{logger: log.New(w, "", log.LstdFlags)}
+}
+
+// NewFileLogger creates a logger writing to stdout, the file of path and the
+// other given writers.
+func NewFileLogger(path string, writers ...io.Writer) *Logger {
 	file := base.MustCreateFile(path)
-	w := io.MultiWriter(os.Stdout, file)
-	logger = log.New(w, "", log.LstdFlags)
+	w := io.MultiWriter(append([]io.Writer{os.Stdout, file}, writers...)...)
+	return &Logger{
+		logger: log.New(w, "", log.LstdFlags),
+		file:   file,
+	}
 }
 
-func Info(v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Printf("[INFO] %s:%d: %s", file, no, fmt.Sprint(v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Info")
+func (l *Logger) Close() {
+	if l.file != nil {
+		l.file.Close()
 	}
 }
 
-func Infof(format string, v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
+var defaultLogger = New(os.Stdout)
+
+func Init(path string) {
+	defaultLogger = NewFileLogger(path)
+}
+
+// SetOutput redirects the logs which are not written by a task logger.
+func SetOutput(w io.Writer) {
+	defaultLogger = New(w)
+}
+
+func (l *Logger) output(level string, msg string) {
+	_, file, no, ok := runtime.Caller(2)
 	if ok {
 		file = filepath.Base(file)
-		logger.Printf("[INFO] %s:%d: %s", file, no, fmt.Sprintf(format, v...))
+		l.logger.Printf("[%s] %s:%d: %s", level, file, no, msg)
 	} else {
-		logger.Fatalf("Fail to get caller info of logger.Infof")
+		l.logger.Fatalf("Fail to get caller info of logger")
 	}
 }
 
-func Warn(v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
+func (l *Logger) fatal(msg string) {
+	_, file, no, ok := runtime.Caller(2)
 	if ok {
 		file = filepath.Base(file)
-		logger.Printf("[WARN] %s:%d: %s", file, no, fmt.Sprint(v...))
+		l.logger.Fatalf("[FATAL] %s:%d: %s", file, no, msg)
 	} else {
-		logger.Fatalf("Fail to get caller info of logger.Warn")
+		l.logger.Fatalf("Fail to get caller info of logger")
 	}
 }
 
+func (l *Logger) Info(v ...interface{}) {
+	l.output("INFO", fmt.Sprint(v...))
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.output("INFO", fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warn(v ...interface{}) {
+	l.output("WARN", fmt.Sprint(v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.output("WARN", fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Error(v ...interface{}) {
+	l.output("ERROR", fmt.Sprint(v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.output("ERROR", fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Fatal(v ...interface{}) {
+	l.fatal(fmt.Sprint(v...))
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.fatal(fmt.Sprintf(format, v...))
+}
+
+func Info(v ...interface{}) {
+	defaultLogger.output("INFO", fmt.Sprint(v...))
+}
+
+func Infof(format string, v ...interface{}) {
+	defaultLogger.output("INFO", fmt.Sprintf(format, v...))
+}
+
+func Warn(v ...interface{}) {
+	defaultLogger.output("WARN", fmt.Sprint(v...))
+}
+
 func Warnf(format string, v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Printf("[WARN] %s:%d: %s", file, no, fmt.Sprintf(format, v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Warnf")
-	}
+	defaultLogger.output("WARN", fmt.Sprintf(format, v...))
 }
 
 func Error(v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Printf("[ERROR] %s:%d: %s", file, no, fmt.Sprint(v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Error")
-	}
+	defaultLogger.output("ERROR", fmt.Sprint(v...))
 }
 
 func Errorf(format string, v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Printf("[ERROR] %s:%d: %s", file, no, fmt.Sprintf(format, v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Errorf")
-	}
+	defaultLogger.output("ERROR", fmt.Sprintf(format, v...))
 }
 
 func Fatal(v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Fatalf("[FATAL] %s:%d: %s", file, no, fmt.Sprint(v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Fatal")
-	}
+	defaultLogger.fatal(fmt.Sprint(v...))
 }
 
 func Fatalf(format string, v ...interface{}) {
-	_, file, no, ok := runtime.Caller(1)
-	if ok {
-		file = filepath.Base(file)
-		logger.Fatalf("[FATAL] %s:%d: %s", file, no, fmt.Sprintf(format, v...))
-	} else {
-		logger.Fatalf("Fail to get caller info of logger.Fatalf")
-	}
+	defaultLogger.fatal(fmt.Sprintf(format, v...))
 }
@@ -1,45 +1,81 @@
 package reader
 
 import (
+	"errors"
+	"sort"
+
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
 )
 
+// Batch buffers the rows sent to a client. The rows are buffered by groups,
+// e.g. the edge types, so that each statement inserts the rows of one group.
 type Batch struct {
 	errCh           chan<- base.ErrData
 	clientRequestCh chan base.ClientRequest
 	bufferSize      int
-	currentIndex    int
-	buffer          []base.Data
+	buffers         map[string]*buffer
 	batchMgr        *BatchMgr
 }
 
+// buffer holds the rows of a group and their formatted values.
+type buffer struct {
+	data   []base.Data
+	values []string
+}
+
 func NewBatch(mgr *BatchMgr, bufferSize int, clientReq chan base.ClientRequest, errCh chan<- base.ErrData) *Batch {
 	b := Batch{
 		errCh:           errCh,
 		clientRequestCh: clientReq,
 		bufferSize:      bufferSize,
-		currentIndex:    0,
-		buffer:          make([]base.Data, bufferSize),
+		buffers:         make(map[string]*buffer),
 		batchMgr:        mgr,
 	}
 	return &b
 }
 
-func (b *Batch) IsFull() bool {
-	return b.currentIndex == b.bufferSize
-}
-
-func (b *Batch) Add(data base.Data) {
-	if b.IsFull() {
-		b.requestClient()
+func (b *Batch) Add(data base.Data) error {
+	group, err := b.batchMgr.group(data)
+	if err != nil {
+		b.SendErrorData(data, err)
+		return err
+	}
+	values, err := b.batchMgr.formatValues(group, data)
+	if errors.Is(err, config.ErrSkipRow) {
+		// The skipped row isn't failed
+		return err
 	}
-	b.buffer[b.currentIndex] = data
-	b.currentIndex++
+	if err != nil {
+		b.SendErrorData(data, err)
+		return err
+	}
+	buf := b.buffers[group]
+	if buf == nil {
+		buf = &buffer{
+			data:   make([]base.Data, 0, b.bufferSize),
+			values: make([]string, 0, b.bufferSize),
+		}
+		b.buffers[group] = buf
+	}
+	buf.data = append(buf.data, data)
+	buf.values = append(buf.values, values)
+	if len(buf.data) == b.bufferSize {
+		b.requestClient(group)
+	}
+	return nil
 }
 
 func (b *Batch) Done() {
-	if b.currentIndex > 0 {
-		b.requestClient()
+	var groups []string
+	for group, buf := range b.buffers {
+		if len(buf.data) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		b.requestClient(group)
 	}
 
 	b.clientRequestCh <- base.ClientRequest{
@@ -48,21 +84,18 @@ func (b *Batch) Done() {
 	}
 }
 
-func (b *Batch) requestClient() {
-	var stmt string
-	if b.batchMgr.Schema.IsVertex() {
-		stmt = b.batchMgr.MakeVertexStmt(b.buffer[:b.currentIndex])
-	} else {
-		stmt = b.batchMgr.MakeEdgeStmt(b.buffer[:b.currentIndex])
-	}
+// requestClient sends the rows of the group to the client. The buffer is owned
+// by the request since then, a new one is allocated for the next rows.
+func (b *Batch) requestClient(group string) {
+	buf := b.buffers[group]
+	delete(b.buffers, group)
 
 	b.clientRequestCh <- base.ClientRequest{
-		Stmt:  stmt,
+		Stmt:  b.batchMgr.MakeStmt(group, buf.data, buf.values),
+		Space: b.batchMgr.space,
 		ErrCh: b.errCh,
-		Data:  b.buffer[:b.currentIndex],
+		Data:  buf.data,
 	}
-
-	b.currentIndex = 0
 }
 
 func (b *Batch) SendErrorData(d base.Data, err error) {
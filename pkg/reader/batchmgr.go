@@ -1,13 +1,17 @@
 package reader
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
 	"github.com/vesoft-inc/nebula-importer/pkg/logger"
 )
 
@@ -15,19 +19,63 @@ type BatchMgr struct {
 	Schema            *config.Schema
 	Batches           []*Batch
 	InsertStmtPrefix  string
+	space             string
 	initializedSchema bool
+	inferTypeRows     int
+	untypedProps      []*config.Prop
+	// edgeTypes are the edge types by the values of the type column
+	edgeTypes map[string]*edgeType
+	// tagGroups are the vertices of the conditional tags by the tag sets
+	tagGroups map[string]*tagGroup
+	// explode is the column exploded into the rows of its elements
+	explode *config.Explode
+	// env is the environment of the expressions of the file
+	env    *expr.Env
+	logger *logger.Logger
 }
 
-func NewBatchMgr(schema *config.Schema, batchSize int, clientRequestChs []chan base.ClientRequest, errCh chan<- base.ErrData) *BatchMgr {
+type tagGroup struct {
+	vertex           *config.Vertex
+	insertStmtPrefix string
+}
+
+type edgeType struct {
+	edge             *config.Edge
+	insertStmtPrefix string
+}
+
+func NewBatchMgr(schema *config.Schema, batchSize int, clientRequestChs []chan base.ClientRequest, errCh chan<- base.ErrData, l *logger.Logger) *BatchMgr {
 	bm := BatchMgr{
 		Schema:            &config.Schema{},
 		Batches:           make([]*Batch, len(clientRequestChs)),
 		initializedSchema: false,
+		logger:            l,
 	}
 
 	bm.Schema.Type = schema.Type
+	if !schema.IsVertex() && schema.Edge != nil {
+		bm.explode = schema.Edge.Explode
+	}
 
-	if bm.Schema.IsVertex() {
+	if schema.UsesConfiguredSchema() {
+		// The edge types, the conditional tags and the expressions are only
+		// configured in the schema
+		bm.Schema = schema
+		bm.initializedSchema = true
+		if schema.IsVertex() && schema.Vertex.HasConditions() {
+			bm.tagGroups = make(map[string]*tagGroup)
+		} else if !schema.IsVertex() && schema.Edge.HasTypes() {
+			bm.edgeTypes = make(map[string]*edgeType)
+			for value, edge := range schema.Edge.TypedEdges() {
+				bm.edgeTypes[value] = &edgeType{
+					edge:             edge,
+					insertStmtPrefix: fmt.Sprintf("INSERT EDGE %s(%s) VALUES ", *edge.Name, bm.GeneratePropsString(edge.Props)),
+				}
+			}
+		} else {
+			bm.generateInsertStmtPrefix()
+		}
+	} else if bm.Schema.IsVertex() {
 		index := 0
 		bm.Schema.Vertex = &config.Vertex{
 			VID:  &config.VID{Index: &index},
@@ -58,7 +106,7 @@ func (bm *BatchMgr) Done() {
 
 func (bm *BatchMgr) InitSchema(header base.Record) {
 	if bm.initializedSchema {
-		logger.Info("Batch manager schema has been initialized!")
+		bm.logger.Info("Batch manager schema has been initialized!")
 		return
 	}
 	bm.initializedSchema = true
@@ -66,7 +114,7 @@ func (bm *BatchMgr) InitSchema(header base.Record) {
 		for _, h := range strings.Split(hh, "/") {
 			switch c := strings.ToUpper(h); {
 			case c == base.LABEL_LABEL:
-				logger.Fatalf("Invalid schema: %v", header)
+				bm.logger.Fatalf("Invalid schema: %v", header)
 			case strings.HasPrefix(c, base.LABEL_VID):
 				*bm.Schema.Vertex.VID.Index = i
 				bm.Schema.Vertex.VID.ParseFunction(c)
@@ -104,12 +152,7 @@ func (bm *BatchMgr) addVertexTags(r string, i int) {
 		return
 	}
 	tag := bm.getOrCreateVertexTagByName(tagName)
-	p := config.Prop{
-		Name:  &prop,
-		Type:  &columnType,
-		Index: &i,
-	}
-	tag.Props = append(tag.Props, &p)
+	tag.Props = append(tag.Props, bm.newProp(columnName, prop, columnType, i))
 }
 
 func (bm *BatchMgr) addEdgeProps(r string, i int) {
@@ -119,25 +162,80 @@ func (bm *BatchMgr) addEdgeProps(r string, i int) {
 	if len(res) > 1 {
 		prop = res[1]
 	}
-	p := config.Prop{
-		Name:  &prop,
+	bm.Schema.Edge.Props = append(bm.Schema.Edge.Props, bm.newProp(columnName, prop, columnType, i))
+}
+
+// newProp creates the prop of column i. If the column has no type, the type is
+// inferred from the first rows when it is enabled, otherwise it is string.
+func (bm *BatchMgr) newProp(columnName, name, columnType string, i int) *config.Prop {
+	untyped := columnType == ""
+	if untyped {
+		columnType = "string"
+		if bm.inferTypeRows <= 0 {
+			bm.logger.Warnf("The type of column %d(%s) is not given, reset to %s", i, columnName, columnType)
+		}
+	}
+	p := &config.Prop{
+		Name:  &name,
 		Type:  &columnType,
 		Index: &i,
 	}
-	bm.Schema.Edge.Props = append(bm.Schema.Edge.Props, &p)
+	if untyped && bm.inferTypeRows > 0 {
+		bm.untypedProps = append(bm.untypedProps, p)
+	}
+	return p
+}
+
+// IsInferringTypes reports whether the types of some props are still to be
+// inferred from the rows.
+func (bm *BatchMgr) IsInferringTypes() bool {
+	return len(bm.untypedProps) > 0
+}
+
+// InferTypes infers the types of the props without types from the records.
+func (bm *BatchMgr) InferTypes(records []base.Record) {
+	for _, p := range bm.untypedProps {
+		var values []string
+		for _, r := range records {
+			if *p.Index < len(r) {
+				values = append(values, r[*p.Index])
+			}
+		}
+		*p.Type = base.InferType(values)
+		if *p.Type == "string" {
+			bm.warnMixedTypes(*p.Index, values)
+		}
+	}
+	bm.untypedProps = nil
+}
+
+// warnMixedTypes warns if the values of a column which is inferred as string
+// are of different types, e.g. 1 and true, which are likely to be bad data.
+func (bm *BatchMgr) warnMixedTypes(index int, values []string) {
+	examples := make(map[string]string)
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		typ := base.InferType([]string{v})
+		if _, ok := examples[typ]; !ok {
+			examples[typ] = v
+		}
+	}
+	if len(examples) > 1 {
+		var types []string
+		for typ, v := range examples {
+			types = append(types, fmt.Sprintf("%s(%q)", typ, v))
+		}
+		sort.Strings(types)
+		bm.logger.Warnf("Column %d has values of different types: %s, inferred as string", index, strings.Join(types, ", "))
+	}
 }
 
 func (bm *BatchMgr) generateInsertStmtPrefix() {
 	var builder strings.Builder
 	if bm.Schema.IsVertex() {
-		builder.WriteString("INSERT VERTEX ")
-		for i, tag := range bm.Schema.Vertex.Tags {
-			builder.WriteString(fmt.Sprintf("%s(%s)", *tag.Name, bm.GeneratePropsString(tag.Props)))
-			if i < len(bm.Schema.Vertex.Tags)-1 {
-				builder.WriteString(",")
-			}
-		}
-		builder.WriteString(" VALUES ")
+		builder.WriteString(bm.vertexInsertStmtPrefix(bm.Schema.Vertex.Tags))
 	} else {
 		edge := bm.Schema.Edge
 		builder.WriteString(fmt.Sprintf("INSERT EDGE %s(%s) VALUES ", *edge.Name, bm.GeneratePropsString(edge.Props)))
@@ -145,6 +243,19 @@ func (bm *BatchMgr) generateInsertStmtPrefix() {
 	bm.InsertStmtPrefix = builder.String()
 }
 
+func (bm *BatchMgr) vertexInsertStmtPrefix(tags []*config.Tag) string {
+	var builder strings.Builder
+	builder.WriteString("INSERT VERTEX ")
+	for i, tag := range tags {
+		builder.WriteString(fmt.Sprintf("%s(%s)", *tag.Name, bm.GeneratePropsString(tag.Props)))
+		if i < len(tags)-1 {
+			builder.WriteString(",")
+		}
+	}
+	builder.WriteString(" VALUES ")
+	return builder.String()
+}
+
 func (bm *BatchMgr) GeneratePropsString(props []*config.Prop) string {
 	var builder strings.Builder
 	for i, prop := range props {
@@ -183,7 +294,10 @@ func (bm *BatchMgr) parseTag(s string) (tag, field string) {
 func (bm *BatchMgr) parseProperty(r string) (columnName, columnType string) {
 	res := strings.SplitN(r, ":", 2)
 
-	if len(res) == 1 || res[1] == "" || !base.IsValidType(res[1]) {
+	if len(res) == 1 || res[1] == "" {
+		return res[0], ""
+	} else if !base.IsValidType(res[1]) {
+		bm.logger.Warnf("Invalid type of column %s, reset to string", r)
 		return res[0], "string"
 	} else {
 		return res[0], res[1]
@@ -193,11 +307,21 @@ func (bm *BatchMgr) parseProperty(r string) (columnName, columnType string) {
 var re = regexp.MustCompile(`^([+-]?\d+|hash\("(.+)"\)|uuid\("(.+)"\))$`)
 
 func (bm *BatchMgr) Add(data base.Data) error {
-	var vid string
+	var v *config.VID
 	if bm.Schema.IsVertex() {
-		vid = data.Record[*bm.Schema.Vertex.VID.Index]
+		v = bm.Schema.Vertex.VID
 	} else {
-		vid = data.Record[*bm.Schema.Edge.SrcVID.Index]
+		v = bm.Schema.Edge.SrcVID
+	}
+	// the VID is checked after it is wrapped by the function, since the value
+	// of a function may be any string, e.g. the value of an expression
+	vid, err := v.FormatValue(data.Record, bm.env)
+	if errors.Is(err, config.ErrSkipRow) {
+		return err
+	}
+	if err != nil {
+		bm.Batches[0].SendErrorData(data, err)
+		return err
 	}
 	if !re.MatchString(vid) {
 		err := fmt.Errorf("Invalid vid format: %s", vid)
@@ -205,111 +329,129 @@ func (bm *BatchMgr) Add(data base.Data) error {
 		return err
 	}
 	batchIdx := getBatchId(vid, len(bm.Batches))
-	bm.Batches[batchIdx].Add(data)
-	return nil
+	return bm.Batches[batchIdx].Add(data)
 }
 
-var h = fnv.New32a()
-
-func getBatchId(idStr string, numChans int) uint32 {
-	_, err := h.Write([]byte(idStr))
-	if err != nil {
-		logger.Error(err)
+// group returns the group of the statement the row is inserted by.
+func (bm *BatchMgr) group(data base.Data) (string, error) {
+	if bm.tagGroups != nil {
+		return bm.tagGroup(data)
 	}
-	return h.Sum32() % uint32(numChans)
+	if bm.edgeTypes == nil {
+		return "", nil
+	}
+	idx := *bm.Schema.Edge.TypeColumn.Index
+	if idx >= len(data.Record) {
+		return "", fmt.Errorf("Edge type column %d out range %d of record(%v)", idx, len(data.Record), data.Record)
+	}
+	value := data.Record[idx]
+	if _, ok := bm.edgeTypes[value]; !ok {
+		return "", fmt.Errorf("Unknown edge type %q in column %d", value, idx)
+	}
+	return value, nil
 }
 
-func makeStmt(batch []base.Data, f func([]base.Data) string) string {
-	if len(batch) == 0 {
-		logger.Fatal("Make stmt for empty batch")
+// tagGroup returns the tag set of the row, e.g. "0,2" for the first and the
+// third tags.
+func (bm *BatchMgr) tagGroup(data base.Data) (string, error) {
+	if data.Type == base.DELETE {
+		// All the tags are deleted with the vertex
+		return "", nil
 	}
-
-	if len(batch) == 1 {
-		return f(batch)
+	indexes := bm.Schema.Vertex.MatchTags(data.Record)
+	if len(indexes) == 0 {
+		return "", errors.New("No tag matches the row")
 	}
-
-	var builder strings.Builder
-	lastIdx, length := 0, len(batch)
-	for i := 1; i < length; i++ {
-		if batch[i-1].Type != batch[i].Type {
-			builder.WriteString(f(batch[lastIdx:i]))
-			lastIdx = i
+	keys := make([]string, len(indexes))
+	for i, idx := range indexes {
+		keys[i] = strconv.Itoa(idx)
+	}
+	key := strings.Join(keys, ",")
+	if _, ok := bm.tagGroups[key]; !ok {
+		tags := make([]*config.Tag, len(indexes))
+		for i, idx := range indexes {
+			tags[i] = bm.Schema.Vertex.Tags[idx]
+		}
+		bm.tagGroups[key] = &tagGroup{
+			vertex:           &config.Vertex{VID: bm.Schema.Vertex.VID, Tags: tags},
+			insertStmtPrefix: bm.vertexInsertStmtPrefix(tags),
 		}
 	}
-	builder.WriteString(f(batch[lastIdx:]))
-	return builder.String()
+	return key, nil
 }
 
-func (m *BatchMgr) MakeVertexStmt(batch []base.Data) string {
-	return makeStmt(batch, m.makeVertexBatchStmt)
+var h = fnv.New32a()
+
+func getBatchId(idStr string, numChans int) uint32 {
+	_, err := h.Write([]byte(idStr))
+	if err != nil {
+		logger.Error(err)
+	}
+	return h.Sum32() % uint32(numChans)
 }
 
-func (m *BatchMgr) makeVertexBatchStmt(batch []base.Data) string {
-	length := len(batch)
-	switch batch[length-1].Type {
+// formatValues formats the row as the values of the statement of the group,
+// which are the VID for the rows to delete.
+func (m *BatchMgr) formatValues(group string, data base.Data) (string, error) {
+	switch data.Type {
 	case base.INSERT:
-		return m.makeVertexInsertStmt(batch)
+		if m.Schema.IsVertex() {
+			vertex := m.Schema.Vertex
+			if g, ok := m.tagGroups[group]; ok {
+				vertex = g.vertex
+			}
+			return vertex.FormatValues(data.Record, m.env)
+		}
+		edge := m.Schema.Edge
+		if t, ok := m.edgeTypes[group]; ok {
+			edge = t.edge
+		}
+		return edge.FormatValues(data.Record, m.env)
 	case base.DELETE:
-		return m.makeVertexDeleteStmt(batch)
+		if m.Schema.IsVertex() {
+			return m.Schema.Vertex.VID.FormatValue(data.Record, m.env)
+		}
+		return "", errors.New("Unsupported delete edge")
 	default:
-		logger.Fatalf("Invalid data type: %s", batch[length-1].Type)
-		return ""
+		return "", fmt.Errorf("Invalid data type: %s", data.Type)
 	}
 }
 
-func (m *BatchMgr) makeVertexInsertStmt(data []base.Data) string {
-	var builder strings.Builder
-	builder.WriteString(m.InsertStmtPrefix)
-	batchSize := len(data)
-	for i := 0; i < batchSize; i++ {
-		builder.WriteString(m.Schema.Vertex.FormatValues(data[i].Record))
-		if i < batchSize-1 {
-			builder.WriteString(",")
-		} else {
-			builder.WriteString(";")
-		}
+// MakeStmt makes the statement of the rows of the group from their formatted
+// values.
+func (m *BatchMgr) MakeStmt(group string, batch []base.Data, values []string) string {
+	if len(batch) == 0 {
+		m.logger.Fatal("Make stmt for empty batch")
 	}
 
-	return builder.String()
-}
-
-func (m *BatchMgr) makeVertexDeleteStmt(data []base.Data) string {
 	var builder strings.Builder
-	for _, d := range data {
-		// TODO: delete vertex in batch
-		builder.WriteString(fmt.Sprintf("DELETE VERTEX %s;", d.Record[*m.Schema.Vertex.VID.Index]))
+	lastIdx, length := 0, len(batch)
+	for i := 1; i <= length; i++ {
+		if i < length && batch[i-1].Type == batch[i].Type {
+			continue
+		}
+		switch batch[lastIdx].Type {
+		case base.INSERT:
+			builder.WriteString(m.insertStmtPrefix(group))
+			builder.WriteString(strings.Join(values[lastIdx:i], ","))
+			builder.WriteString(";")
+		case base.DELETE:
+			for _, vid := range values[lastIdx:i] {
+				// TODO: delete vertex in batch
+				builder.WriteString(fmt.Sprintf("DELETE VERTEX %s;", vid))
+			}
+		}
+		lastIdx = i
 	}
 	return builder.String()
 }
 
-func (m *BatchMgr) MakeEdgeStmt(batch []base.Data) string {
-	return makeStmt(batch, m.makeEdgeBatchStmt)
-}
-
-func (m *BatchMgr) makeEdgeBatchStmt(batch []base.Data) string {
-	length := len(batch)
-	switch batch[length-1].Type {
-	case base.INSERT:
-		return m.makeEdgeInsertStmt(batch)
-	case base.DELETE:
-		logger.Fatal("Unsupported delete edge")
-	default:
-		logger.Fatalf("Invalid data type: %s", batch[length-1].Type)
+func (m *BatchMgr) insertStmtPrefix(group string) string {
+	if g, ok := m.tagGroups[group]; ok {
+		return g.insertStmtPrefix
 	}
-	return ""
-}
-
-func (m *BatchMgr) makeEdgeInsertStmt(batch []base.Data) string {
-	var builder strings.Builder
-	builder.WriteString(m.InsertStmtPrefix)
-	batchSize := len(batch)
-	for i := 0; i < batchSize; i++ {
-		builder.WriteString(m.Schema.Edge.FormatValues(batch[i].Record))
-		if i < batchSize-1 {
-			builder.WriteString(",")
-		} else {
-			builder.WriteString(";")
-		}
+	if t, ok := m.edgeTypes[group]; ok {
+		return t.insertStmtPrefix
 	}
-	return builder.String()
+	return m.InsertStmtPrefix
 }
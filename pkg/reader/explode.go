@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+)
+
+// explodeRow returns the rows of the elements of the exploded column, which
+// share the other columns of the row, or the row itself if no column is
+// exploded. The rows refer to the row as their source, which is identified by
+// sourceKey, so that it is written to the failed data once instead of the
+// elements.
+func (bm *BatchMgr) explodeRow(data base.Data, sourceKey string) ([]base.Data, error) {
+	if bm.explode == nil {
+		return []base.Data{data}, nil
+	}
+	idx := *bm.explode.Index
+	if idx >= len(data.Record) {
+		return nil, fmt.Errorf("Explode column %d out range %d of record(%v)", idx, len(data.Record), data.Record)
+	}
+	elements, err := bm.splitCell(data.Record[idx])
+	if err != nil {
+		return nil, fmt.Errorf("Fail to explode column %d: %v", idx, err)
+	}
+	rows := make([]base.Data, 0, len(elements))
+	for _, e := range elements {
+		record := make(base.Record, len(data.Record))
+		copy(record, data.Record)
+		record[idx] = e
+		rows = append(rows, base.Data{Type: data.Type, Record: record, Source: data.Record, SourceKey: sourceKey})
+	}
+	return rows, nil
+}
+
+// splitCell splits the cell by the delimiter, or as a JSON array. The empty
+// elements are dropped.
+func (bm *BatchMgr) splitCell(cell string) ([]string, error) {
+	var elements []string
+	if bm.explode.IsJSON() {
+		if strings.TrimSpace(cell) == "" {
+			return nil, nil
+		}
+		d := json.NewDecoder(strings.NewReader(cell))
+		d.UseNumber()
+		var values []interface{}
+		if err := d.Decode(&values); err != nil {
+			return nil, fmt.Errorf("Invalid JSON array %q: %v", cell, err)
+		}
+		for _, v := range values {
+			elements = append(elements, jsonString(v))
+		}
+	} else {
+		elements = strings.Split(cell, *bm.explode.Delimiter)
+	}
+
+	var result []string
+	for _, e := range elements {
+		if e = strings.TrimSpace(e); e != "" {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/csv"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+func TestSplitCell(t *testing.T) {
+	index, yes, pipe := 1, true, "|"
+	for _, c := range []struct {
+		explode  config.Explode
+		cell     string
+		expected []string
+	}{
+		{config.Explode{Index: &index, Delimiter: &pipe}, "a| b ||c", []string{"a", "b", "c"}},
+		{config.Explode{Index: &index, Delimiter: &pipe}, " ", nil},
+		{config.Explode{Index: &index, JSON: &yes}, `["a", 1, 2.50, true, null, ""]`, []string{"a", "1", "2.50", "true"}},
+		{config.Explode{Index: &index, JSON: &yes}, "", nil},
+	} {
+		bm := &BatchMgr{explode: &c.explode}
+		elements, err := bm.splitCell(c.cell)
+		if err != nil {
+			t.Errorf("Fail to split %q: %v", c.cell, err)
+		} else if !reflect.DeepEqual(elements, c.expected) {
+			t.Errorf("Error elements of %q: %q, expected %q", c.cell, elements, c.expected)
+		}
+	}
+
+	bm := &BatchMgr{explode: &config.Explode{Index: &index, JSON: &yes}}
+	if _, err := bm.splitCell("[a"); err == nil || !strings.Contains(err.Error(), "Invalid JSON array") {
+		t.Errorf("The invalid JSON array should be rejected: %v", err)
+	}
+}
+
+func TestExplodeRow(t *testing.T) {
+	index, pipe := 1, "|"
+	bm := &BatchMgr{explode: &config.Explode{Index: &index, Delimiter: &pipe}}
+	data := base.InsertData(base.Record{"1", "2|3", "x"})
+	rows, err := bm.explodeRow(data, "a.csv:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !reflect.DeepEqual(rows[0].Record, base.Record{"1", "2", "x"}) || !reflect.DeepEqual(rows[1].Record, base.Record{"1", "3", "x"}) {
+		t.Fatalf("Error exploded rows: %v", rows)
+	}
+	for _, row := range rows {
+		if !reflect.DeepEqual(row.Source, data.Record) || row.SourceKey != "a.csv:2" || row.Type != base.INSERT {
+			t.Errorf("Error source of row %v: %v, %s", row.Record, row.Source, row.SourceKey)
+		}
+	}
+	if data.Record[1] != "2|3" {
+		t.Errorf("The source row is changed: %v", data.Record)
+	}
+
+	if _, err := bm.explodeRow(base.InsertData(base.Record{"1"}), "a.csv:3"); err == nil {
+		t.Error("The missing column should fail to be exploded")
+	}
+	if rows, _ := (&BatchMgr{}).explodeRow(data, "a.csv:2"); len(rows) != 1 || rows[0].Source != nil {
+		t.Errorf("The row should not be exploded without explode: %v", rows)
+	}
+}
+
+func TestExplodeFailData(t *testing.T) {
+	conf := `
+version: v1rc2
+clientSettings:
+  space: test
+  connection:
+    address: 127.0.0.1:3699
+logPath: ./test.log
+files:
+  - path: ./follow.csv
+    failDataPath: ./err/follow.csv
+    batchSize: 10
+    inOrder: true
+    type: csv
+    csv:
+      withHeader: false
+    schema:
+      type: edge
+      edge:
+        name: follow
+        explode:
+          index: 0
+          delimiter: ";"
+        srcVID:
+          index: 0
+        dstVID:
+          index: 1
+`
+	result := readFile(t, conf, map[string]string{"follow.csv": "1;2,10\nx;y,11\n1;z,12\n"})
+	if len(result.stmts) != 1 || !strings.Contains(result.stmts[0], " 1->10:() , 2->10:() , 1->12:() ") {
+		t.Errorf("Error statements: %v", result.stmts)
+	}
+	if len(result.errs) != 3 {
+		t.Fatalf("Each element should fail: %v", result.errs)
+	}
+
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "follow.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	withLabel := false
+	w := csv.NewErrDataWriter(&config.CSVConfig{WithLabel: &withLabel}, logger.New(ioutil.Discard))
+	w.Init(f)
+	// The elements of a row fail separately
+	for _, e := range result.errs {
+		w.Write(e.Data)
+	}
+	w.Flush()
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "x;y,11\n1;z,12\n" {
+		t.Errorf("The failed rows should be written once:\n%s", b)
+	}
+}
@@ -0,0 +1,187 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
+)
+
+// lookupTable is a loaded lookup of a file.
+type lookupTable struct {
+	config *config.Lookup
+	values map[string]string
+}
+
+// newLookupFunc loads the lookups of the file once, and returns the lookup
+// function of the expressions, lookup("name", key).
+func newLookupFunc(lookups []*config.Lookup) (expr.Func, error) {
+	tables := make(map[string]*lookupTable, len(lookups))
+	for _, l := range lookups {
+		values, err := loadLookup(l)
+		if err != nil {
+			return nil, fmt.Errorf("Fail to load lookup %s from %s: %v", *l.Name, *l.Path, err)
+		}
+		tables[*l.Name] = &lookupTable{config: l, values: values}
+	}
+
+	return func(args []expr.Value) (expr.Value, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Expect 2 arguments, the name and the key")
+		}
+		name := expr.ToString(args[0])
+		t, ok := tables[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown lookup %s", name)
+		}
+		key := expr.ToString(args[1])
+		if v, ok := t.values[key]; ok {
+			return v, nil
+		}
+		switch t.config.MissingAction() {
+		case config.ON_NULL_DEFAULT:
+			return *t.config.Default, nil
+		case config.ON_NULL_SKIP_ROW:
+			return nil, fmt.Errorf("Missing key %q in lookup %s: %w", key, name, config.ErrSkipRow)
+		default:
+			return nil, fmt.Errorf("Missing key %q in lookup %s", key, name)
+		}
+	}, nil
+}
+
+func loadLookup(l *config.Lookup) (map[string]string, error) {
+	file, err := os.Open(*l.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if *l.Type == config.LOOKUP_JSONL {
+		return loadJSONLLookup(file, *l.Key, *l.Value)
+	}
+	return loadCSVLookup(file, []rune(*l.Delimiter)[0], *l.Key, *l.Value)
+}
+
+// loadCSVLookup loads the CSV file, whose header names the key and value
+// columns.
+func loadCSVLookup(file io.Reader, delimiter rune, key, value string) (map[string]string, error) {
+	r := csv.NewReader(bufio.NewReader(file))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Fail to read the header: %v", err)
+	}
+	keyIdx, valueIdx := -1, -1
+	for i, name := range header {
+		switch name {
+		case key:
+			keyIdx = i
+		case value:
+			valueIdx = i
+		}
+	}
+	if keyIdx < 0 || valueIdx < 0 {
+		return nil, fmt.Errorf("The header(%v) has no column %s or %s", header, key, value)
+	}
+
+	values := newLookupValues()
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return values.values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if keyIdx >= len(record) || valueIdx >= len(record) {
+			return nil, fmt.Errorf("Line %d has no column %s or %s", line, key, value)
+		}
+		if err := values.add(record[keyIdx], record[valueIdx], line); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loadJSONLLookup loads the JSON lines file, each line is an object of the key
+// and value fields.
+func loadJSONLLookup(file io.Reader, key, value string) (map[string]string, error) {
+	values := newLookupValues()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var obj map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		// The numbers are kept as they are written
+		d.UseNumber()
+		if err := d.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		k, ok := obj[key]
+		if !ok {
+			return nil, fmt.Errorf("Line %d has no field %s", line, key)
+		}
+		v, ok := obj[value]
+		if !ok {
+			return nil, fmt.Errorf("Line %d has no field %s", line, value)
+		}
+		if err := values.add(jsonString(k), jsonString(v), line); err != nil {
+			return nil, err
+		}
+	}
+	return values.values, scanner.Err()
+}
+
+// lookupValues are the values of a lookup file by the keys, and the lines of
+// the keys to report the duplicate keys.
+type lookupValues struct {
+	values map[string]string
+	lines  map[string]int
+}
+
+func newLookupValues() *lookupValues {
+	return &lookupValues{
+		values: make(map[string]string),
+		lines:  make(map[string]int),
+	}
+}
+
+// add adds the value of the key in the line. A key can be repeated with the
+// same value, but not with a different one, which is ambiguous.
+func (l *lookupValues) add(key, value string, line int) error {
+	if v, ok := l.values[key]; ok {
+		if v != value {
+			return fmt.Errorf("Line %d: duplicate key %q of value %q, which is %q in line %d", line, key, value, v, l.lines[key])
+		}
+		return nil
+	}
+	l.values[key] = value
+	l.lines[key] = line
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return fmt.Sprint(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
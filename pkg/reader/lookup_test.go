@@ -0,0 +1,124 @@
+package reader
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
+)
+
+func TestLoadLookups(t *testing.T) {
+	for _, c := range []struct {
+		content  string
+		jsonl    bool
+		expected map[string]string
+		err      string
+	}{
+		{"name;code\nFrance;FR\n\"Côte d'Ivoire; CI\";CI\nFrance;FR\n", false, map[string]string{"FR": "France", "CI": "Côte d'Ivoire; CI"}, ""},
+		{"code;name\nFR\n", false, nil, "Line 2 has no column code or name"},
+		{"code;label\nFR;France\n", false, nil, "has no column code or name"},
+		{"code;name\nFR;France\nFR;Francia\n", false, nil, `Line 3: duplicate key "FR" of value "Francia", which is "France" in line 2`},
+		{"{\"code\": 33, \"name\": 1.50}\n\n{\"code\": \"CI\", \"name\": {\"en\": \"Ivory Coast\"}}\n", true, map[string]string{"33": "1.50", "CI": `{"en":"Ivory Coast"}`}, ""},
+		{"{\"code\": \"FR\"}\n", true, nil, "Line 1 has no field name"},
+		{"{\"code\": \"FR\", \"name\": \"France\"}\n{\"code\": \"FR\", \"name\": null}\n", true, nil, `Line 2: duplicate key "FR"`},
+		{"[1]\n", true, nil, "Line 1:"},
+	} {
+		var values map[string]string
+		var err error
+		if c.jsonl {
+			values, err = loadJSONLLookup(strings.NewReader(c.content), "code", "name")
+		} else {
+			values, err = loadCSVLookup(strings.NewReader(c.content), ';', "code", "name")
+		}
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Error of %q should contain %q: %v", c.content, c.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("Fail to load %q: %v", c.content, err)
+		} else if !reflect.DeepEqual(values, c.expected) {
+			t.Errorf("Error values of %q: %v", c.content, values)
+		}
+	}
+}
+
+func TestLookupFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "countries.csv")
+	if err := ioutil.WriteFile(path, []byte("code,name\nFR,France\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newLookup := func(name, onMissing string) *config.Lookup {
+		typ, delimiter, key, value, unknown := config.LOOKUP_CSV, ",", "code", "name", "Unknown"
+		return &config.Lookup{Name: &name, Path: &path, Type: &typ, Delimiter: &delimiter, Key: &key, Value: &value, OnMissing: &onMissing, Default: &unknown}
+	}
+	lookup, err := newLookupFunc([]*config.Lookup{
+		newLookup("default", config.ON_NULL_DEFAULT),
+		newLookup("skip", config.ON_NULL_SKIP_ROW),
+		newLookup("error", config.ON_NULL_ERROR),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(name, key string) (string, error) {
+		v, err := lookup([]expr.Value{name, key})
+		return expr.ToString(v), err
+	}
+	if v, err := call("error", "FR"); err != nil || v != "France" {
+		t.Errorf("Error value of FR: %s, %v", v, err)
+	}
+	if v, err := call("default", "DE"); err != nil || v != "Unknown" {
+		t.Errorf("The missing key should be the default value: %s, %v", v, err)
+	}
+	if _, err := call("skip", "DE"); !errors.Is(err, config.ErrSkipRow) {
+		t.Errorf("The missing key should skip the row: %v", err)
+	}
+	if _, err := call("error", "DE"); err == nil || errors.Is(err, config.ErrSkipRow) || !strings.Contains(err.Error(), `Missing key "DE" in lookup error`) {
+		t.Errorf("The missing key should be an error: %v", err)
+	}
+	if _, err := call("brands", "DE"); err == nil || !strings.Contains(err.Error(), "Unknown lookup brands") {
+		t.Errorf("The unknown lookup should be an error: %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing.csv")
+	l := newLookup("missing", config.ON_NULL_ERROR)
+	l.Path = &missing
+	if _, err := newLookupFunc([]*config.Lookup{l}); err == nil || !strings.Contains(err.Error(), "Fail to load lookup missing") {
+		t.Errorf("The missing lookup file should fail to be loaded: %v", err)
+	}
+}
+
+func TestLookupSkipRow(t *testing.T) {
+	conf := strings.Replace(ordersYAML, "    csv:\n", `    lookups:
+      - name: products
+        path: ./products.csv
+        key: id
+        value: name
+        onMissing: skip-row
+    csv:
+`, 1)
+	conf = strings.Replace(conf, "          index: 2\n", "          expr: lookup(\"products\", product)\n", 1)
+	result := readFile(t, conf, map[string]string{
+		"orders.csv":   "tenant,customer,product,amount\nacme,42,7,1.5\nacme,43,8,2\n",
+		"products.csv": "id,name\n7,100\n",
+	})
+	if len(result.errs) > 0 {
+		t.Fatal(result.errs[0].Error)
+	}
+	if len(result.stmts) != 1 || !strings.Contains(result.stmts[0], `hash("acme:42")->100:(1.5)`) || strings.Contains(result.stmts[0], "acme:43") {
+		t.Errorf("Error statements: %v", result.stmts)
+	}
+	if result.skipped != 1 {
+		t.Errorf("The row of the missing key should be skipped: %d", result.skipped)
+	}
+}
@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
 	"github.com/vesoft-inc/nebula-importer/pkg/csv"
+	"github.com/vesoft-inc/nebula-importer/pkg/expr"
 	"github.com/vesoft-inc/nebula-importer/pkg/logger"
 )
 
@@ -24,24 +26,55 @@ type FileReader struct {
 	WithHeader  bool
 	DataReader  DataFileReader
 	Concurrency int
-	BatchMgr    *BatchMgr
-	StopFlag    bool
+	// BatchMgrs are the batch managers of the schemas of the file, each row is
+	// added to all of them
+	BatchMgrs []*BatchMgr
+	StopFlag  bool
+	// env is the environment of the expressions of the file, shared by the
+	// batch managers
+	env     *expr.Env
+	statsCh chan<- base.Stats
+	// skippedWriter writes the rows skipped by the filter, if configured
+	skippedWriter *csv.ErrWriter
+	numSkipped    int
+	logger        *logger.Logger
 }
 
-func New(fileIdx int, file *config.File, clientRequestChs []chan base.ClientRequest, errCh chan<- base.ErrData) (*FileReader, error) {
+// New creates the reader of the file, errChs are the channels of the failed
+// rows of the schemas of the file, in the order of file.Mappings(), and the
+// rows skipped by the filter are counted to statsCh.
+func New(fileIdx int, file *config.File, clientRequestChs []chan base.ClientRequest, errChs []chan base.ErrData, statsCh chan<- base.Stats, l *logger.Logger) (*FileReader, error) {
 	switch strings.ToLower(*file.Type) {
 	case "csv":
-		r := csv.CSVReader{CSVConfig: file.CSV}
+		r := csv.CSVReader{CSVConfig: file.CSV, Logger: l}
 		reader := FileReader{
 			FileIdx:    fileIdx,
 			DataReader: &r,
 			File:       file,
 			WithHeader: *file.CSV.WithHeader,
 			StopFlag:   false,
+			env:        &expr.Env{},
+			statsCh:    statsCh,
+			logger:     l,
 		}
-		reader.BatchMgr = NewBatchMgr(file.Schema, *file.BatchSize, clientRequestChs, errCh)
-		if !reader.WithHeader {
-			reader.BatchMgr.InitSchema(strings.Split(file.Schema.String(), ","))
+		if len(file.Lookups) > 0 {
+			lookup, err := newLookupFunc(file.Lookups)
+			if err != nil {
+				return nil, err
+			}
+			reader.env.Funcs = map[string]expr.Func{"lookup": lookup}
+		}
+		for i, schema := range file.Mappings() {
+			bm := NewBatchMgr(schema, *file.BatchSize, clientRequestChs, errChs[i], l)
+			bm.space = *file.Space
+			bm.env = reader.env
+			if file.CSV.IsInferType() {
+				bm.inferTypeRows = *file.CSV.InferTypeRows
+			}
+			if (!reader.WithHeader || reader.skipHeader()) && !bm.initializedSchema {
+				bm.InitSchema(strings.Split(schema.String(), ","))
+			}
+			reader.BatchMgrs = append(reader.BatchMgrs, bm)
 		}
 		return &reader, nil
 	default:
@@ -49,14 +82,41 @@ func New(fileIdx int, file *config.File, clientRequestChs []chan base.ClientRequ
 	}
 }
 
+// skipHeader reports whether the header is skipped instead of defining the
+// schema, which is the case of the files with several schemas, or with the
+// schema used as it is configured, or with csv.skipHeader.
+func (r *FileReader) skipHeader() bool {
+	if len(r.File.Schemas) > 0 || r.File.CSV.IsSkipHeader() {
+		return true
+	}
+	return r.File.Schema != nil && r.File.Schema.UsesConfiguredSchema()
+}
+
 func (r *FileReader) startLog(filename string) {
-	logger.Infof("Start to read file(%d): %s, schema: < %s >", r.FileIdx, filename, r.BatchMgr.Schema.String())
+	for _, bm := range r.BatchMgrs {
+		r.logger.Infof("Start to read file(%d): %s, space: %s, schema: < %s >", r.FileIdx, filename, bm.space, bm.Schema.String())
+	}
+}
+
+func (r *FileReader) isInferringTypes() bool {
+	for _, bm := range r.BatchMgrs {
+		if bm.IsInferringTypes() {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *FileReader) Stop() {
 	r.StopFlag = true
 }
 
+// sampledData is a row kept to infer the prop types.
+type sampledData struct {
+	data    base.Data
+	lineNum int64
+}
+
 func (r *FileReader) ReadFile(filename string) (lineNum int64, numErrorLines int64, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -66,10 +126,30 @@ func (r *FileReader) ReadFile(filename string) (lineNum int64, numErrorLines int
 
 	r.DataReader.InitReader(file)
 
-	if !r.WithHeader {
+	if (!r.WithHeader || r.skipHeader()) && !r.isInferringTypes() {
 		r.startLog(filename)
 	}
 
+	// The rows are held back until the types are inferred from them
+	var sampled []sampledData
+	inferTypes := func() {
+		records := make([]base.Record, 0, len(sampled))
+		for _, s := range sampled {
+			records = append(records, s.data.Record)
+		}
+		for _, bm := range r.BatchMgrs {
+			bm.InferTypes(records)
+		}
+		r.startLog(filename)
+		for _, s := range sampled {
+			if err := r.add(s.data, filename, s.lineNum); err != nil {
+				r.logger.Errorf("Fail to read line %d, error: %s", s.lineNum, err.Error())
+				numErrorLines++
+			}
+		}
+		sampled = nil
+	}
+
 	for {
 		data, err := r.DataReader.ReadLine()
 		if err == io.EOF {
@@ -80,20 +160,27 @@ func (r *FileReader) ReadFile(filename string) (lineNum int64, numErrorLines int
 
 		if err == nil {
 			if data.Type == base.HEADER {
-				r.BatchMgr.InitSchema(data.Record)
-				r.startLog(filename)
-			} else {
-				if *r.File.InOrder {
-					err = r.BatchMgr.Add(data)
-				} else {
-					idx := lineNum % int64(len(r.BatchMgr.Batches))
-					r.BatchMgr.Batches[idx].Add(data)
+				r.setColumns(data.Record)
+				if !r.skipHeader() {
+					r.BatchMgrs[0].InitSchema(data.Record)
+					if !r.isInferringTypes() {
+						r.startLog(filename)
+					}
 				}
+			} else if skip, ferr := r.skip(data); skip || ferr != nil {
+				err = ferr
+			} else if r.isInferringTypes() {
+				sampled = append(sampled, sampledData{data, lineNum})
+				if len(sampled) >= r.BatchMgrs[0].inferTypeRows {
+					inferTypes()
+				}
+			} else {
+				err = r.add(data, filename, lineNum)
 			}
 		}
 
 		if err != nil {
-			logger.Errorf("Fail to read line %d, error: %s", lineNum, err.Error())
+			r.logger.Errorf("Fail to read line %d, error: %s", lineNum, err.Error())
 			numErrorLines++
 		}
 
@@ -102,10 +189,128 @@ func (r *FileReader) ReadFile(filename string) (lineNum int64, numErrorLines int
 		}
 	}
 
+	if len(sampled) > 0 && !r.StopFlag {
+		inferTypes()
+	}
+
 	return
 }
 
+// setColumns names the columns referred by the expressions by the header.
+func (r *FileReader) setColumns(header base.Record) {
+	r.env.Columns = make(map[string]int, len(header))
+	for i, name := range header {
+		r.env.Columns[strings.TrimSpace(name)] = i
+	}
+}
+
+// skip reports whether the row is skipped by the filter of the file, or by a
+// lookup of the filter for a missing key. The skipped rows are written to the
+// skipped rows file, and the rows the filter fails to be evaluated with are
+// written to the failed data files.
+func (r *FileReader) skip(data base.Data) (bool, error) {
+	skip, err := r.File.Skip(data.Record, r.env)
+	if errors.Is(err, config.ErrSkipRow) {
+		skip, err = true, nil
+	}
+	if err != nil {
+		for _, bm := range r.BatchMgrs {
+			bm.Batches[0].SendErrorData(data, err)
+		}
+		return false, err
+	}
+	if skip {
+		r.skipped(data, 1)
+	}
+	return skip, nil
+}
+
+// skipped writes the skipped row to the skipped rows file and counts it n
+// times.
+func (r *FileReader) skipped(data base.Data, n int) {
+	if r.skippedWriter != nil {
+		r.skippedWriter.Write([]base.Data{data})
+	}
+	r.numSkipped += n
+	if r.numSkipped >= *r.File.BatchSize {
+		r.flushSkipped()
+	}
+}
+
+// flushSkipped counts the skipped rows to the stats.
+func (r *FileReader) flushSkipped() {
+	if r.numSkipped > 0 {
+		r.statsCh <- base.NewSkippedStats(r.numSkipped, *r.File.Space)
+		r.numSkipped = 0
+	}
+}
+
+// add adds the row to the batch managers, or the rows of the elements of the
+// exploded column. Each batch manager skips the row on its own, for a null
+// value or no element at all, while the others still add it, the same as the
+// failed rows, so the row is counted as skipped by each batch manager skipping
+// it, and is written to the skipped rows file once. The row is identified by
+// the filename and the line number as the source of the exploded rows.
+func (r *FileReader) add(data base.Data, filename string, lineNum int64) error {
+	var errs []string
+	numSkipped := 0
+	sourceKey := fmt.Sprintf("%s:%d", filename, lineNum)
+	for _, bm := range r.BatchMgrs {
+		rows, err := bm.explodeRow(data, sourceKey)
+		if err != nil {
+			bm.Batches[0].SendErrorData(data, err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		skipped := len(rows) == 0
+		for _, row := range rows {
+			if *r.File.InOrder {
+				err = bm.Add(row)
+			} else {
+				err = bm.Batches[lineNum%int64(len(bm.Batches))].Add(row)
+			}
+			if errors.Is(err, config.ErrSkipRow) {
+				skipped = true
+			} else if err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if skipped {
+			numSkipped++
+		}
+	}
+	if numSkipped > 0 {
+		r.skipped(data, numSkipped)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 func (r *FileReader) Read() error {
+	if r.File.SkippedRowsPath != nil {
+		skippedFile := base.MustCreateFile(*r.File.SkippedRowsPath)
+		defer skippedFile.Close()
+		r.skippedWriter = csv.NewErrDataWriter(r.File.CSV, r.logger)
+		r.skippedWriter.Init(skippedFile)
+		defer func() {
+			r.skippedWriter.Flush()
+			if err := r.skippedWriter.Error(); err != nil {
+				r.logger.Error(err)
+			}
+		}()
+	}
+
+	// The clients are told that the file is done even if it fails to be read,
+	// otherwise the stage of the file never finishes
+	defer func() {
+		r.flushSkipped()
+		for _, bm := range r.BatchMgrs {
+			bm.Done()
+		}
+	}()
+
 	var lineNumTotal int64
 	var numErrorLinesTotal int64
 	for _, filename := range r.File.Paths {
@@ -113,12 +318,11 @@ func (r *FileReader) Read() error {
 		if err != nil {
 			return err
 		}
-		logger.Infof("Total lines of file(%s) is: %d, error lines: %d", filename, lineNum, numErrorLines)
+		r.logger.Infof("Total lines of file(%s) is: %d, error lines: %d", filename, lineNum, numErrorLines)
 		lineNumTotal = lineNumTotal + lineNum
 		numErrorLinesTotal = numErrorLinesTotal + numErrorLines
 	}
 
-	r.BatchMgr.Done()
-	logger.Infof("Total lines of path(%s) is: %d, error lines: %d", *r.File.Path, lineNumTotal, numErrorLinesTotal)
+	r.logger.Infof("Total lines of path(%s) is: %d, error lines: %d", *r.File.Path, lineNumTotal, numErrorLinesTotal)
 	return nil
 }
@@ -0,0 +1,219 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/base"
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// readResult is what the reader sends of a file.
+type readResult struct {
+	stmts   []string
+	errs    []base.ErrData
+	skipped int
+}
+
+// readFile reads the first file of the config with the files in a temporary
+// directory, by one client.
+func readFile(t *testing.T, conf string, files map[string]string) readResult {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files["config.yaml"] = conf
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	yaml, err := config.Parse(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := yaml.Files[0]
+	clientRequestCh := make(chan base.ClientRequest, 100)
+	errChs := make([]chan base.ErrData, len(file.Mappings()))
+	for i := range errChs {
+		errChs[i] = make(chan base.ErrData, 100)
+	}
+	statsCh := make(chan base.Stats, 100)
+	r, err := New(0, file, []chan base.ClientRequest{clientRequestCh}, errChs, statsCh, logger.New(ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	var result readResult
+	close(clientRequestCh)
+	for req := range clientRequestCh {
+		if req.Stmt != base.STAT_FILEDONE {
+			result.stmts = append(result.stmts, req.Stmt)
+		}
+	}
+	for _, ch := range errChs {
+		close(ch)
+		for e := range ch {
+			result.errs = append(result.errs, e)
+		}
+	}
+	close(statsCh)
+	for s := range statsCh {
+		if s.Type == base.SKIPPED {
+			result.skipped += s.BatchSize
+		}
+	}
+	return result
+}
+
+const ordersYAML = `
+version: v1rc2
+clientSettings:
+  space: test
+  connection:
+    address: 127.0.0.1:3699
+logPath: ./test.log
+files:
+  - path: ./orders.csv
+    failDataPath: ./err/orders.csv
+    batchSize: 10
+    inOrder: true
+    type: csv
+    csv:
+      withHeader: true
+    schema:
+      type: edge
+      edge:
+        name: order
+        srcVID:
+          expr: concat(tenant, ":", customer)
+          function: hash
+        dstVID:
+          index: 2
+        props:
+          - name: amount
+            type: double
+            index: 3
+`
+
+func TestExprVID(t *testing.T) {
+	result := readFile(t, ordersYAML, map[string]string{
+		"orders.csv": "tenant,customer,product,amount\nacme,42,7,1.5\nacme,x y,8,2\n",
+	})
+	if len(result.errs) > 0 {
+		t.Fatalf("The VIDs of the expression should be valid: %v", result.errs[0].Error)
+	}
+	expected := `INSERT EDGE order(amount) VALUES  hash("acme:42")->7:(1.5) , hash("acme:x y")->8:(2) ;`
+	if len(result.stmts) != 1 || result.stmts[0] != expected {
+		t.Errorf("Error statements: %v", result.stmts)
+	}
+
+	conf := strings.Replace(ordersYAML, "          expr: concat(tenant, \":\", customer)\n          function: hash\n", "          expr: customer\n", 1)
+	result = readFile(t, conf, map[string]string{
+		"orders.csv": "tenant,customer,product,amount\nacme,42,7,1.5\nacme,x,8,2\n",
+	})
+	if len(result.errs) != 1 || !strings.Contains(result.errs[0].Error.Error(), "Invalid vid format: x") {
+		t.Errorf("The VID without function should be checked: %v", result.errs)
+	}
+	if len(result.stmts) != 1 || !strings.Contains(result.stmts[0], " 42->7:") {
+		t.Errorf("Error statements: %v", result.stmts)
+	}
+}
+
+func TestSkipHeader(t *testing.T) {
+	conf := `
+version: v1rc2
+clientSettings:
+  space: test
+  connection:
+    address: 127.0.0.1:3699
+logPath: ./test.log
+files:
+  - path: ./person.csv
+    failDataPath: ./err/person.csv
+    batchSize: 10
+    type: csv
+    csv:
+      withHeader: true
+      skipHeader: true
+    schema:
+      type: vertex
+      vertex:
+        vid:
+          index: 1
+        tags:
+          - name: person
+            props:
+              - name: name
+                type: string
+                index: 0
+`
+	result := readFile(t, conf, map[string]string{"person.csv": "name,id\nTom,1\n"})
+	if len(result.errs) > 0 || len(result.stmts) != 1 || result.stmts[0] != `INSERT VERTEX person(name) VALUES  1: ("Tom");` {
+		t.Errorf("The header should be skipped: %v, %v", result.stmts, result.errs)
+	}
+}
+
+func TestSkipRowOfSchemas(t *testing.T) {
+	conf := `
+version: v1rc2
+clientSettings:
+  space: test
+  connection:
+    address: 127.0.0.1:3699
+logPath: ./test.log
+files:
+  - path: ./users.csv
+    failDataPath: ./err/users.csv
+    batchSize: 10
+    type: csv
+    csv:
+      withHeader: false
+    schemas:
+      - type: vertex
+        vertex:
+          vid:
+            index: 0
+          tags:
+            - name: user
+              props:
+                - name: age
+                  type: int
+                  index: 1
+                  onNull: skip-row
+      - type: vertex
+        vertex:
+          vid:
+            index: 2
+          tags:
+            - name: order
+              props:
+                - name: amount
+                  type: double
+                  index: 3
+                  onNull: skip-row
+`
+	result := readFile(t, conf, map[string]string{"users.csv": "1,20,100,1.5\n2,,101,2.5\n3,,102,\n"})
+	if len(result.errs) > 0 {
+		t.Fatal(result.errs[0].Error)
+	}
+	expected := []string{
+		`INSERT VERTEX user(age) VALUES  1: (20);`,
+		`INSERT VERTEX order(amount) VALUES  100: (1.5), 101: (2.5);`,
+	}
+	if !reflect.DeepEqual(result.stmts, expected) {
+		t.Errorf("The row should only be skipped by the schema of the null value: %v", result.stmts)
+	}
+	if result.skipped != 3 {
+		t.Errorf("The row should be counted as skipped by each schema skipping it: %d", result.skipped)
+	}
+}
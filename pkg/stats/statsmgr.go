@@ -2,6 +2,8 @@ package stats
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/vesoft-inc/nebula-importer/pkg/base"
@@ -9,24 +11,56 @@ import (
 )
 
 type StatsMgr struct {
-	StatsCh      chan base.Stats
-	DoneCh       chan bool
+	StatsCh chan base.Stats
+	DoneCh  chan bool
+	// FileDoneCh receives the path of each file when it is done
+	FileDoneCh   chan string
 	NumFailed    int64
+	numSkipped   int64
 	totalCount   int64
 	totalBatches int64
 	totalLatency int64
 	totalReqTime int64
+	startTime    time.Time
+	spaces       map[string]*SpaceStats
+	logger       *logger.Logger
+	mux          sync.Mutex
 }
 
-func NewStatsMgr(numReadingFiles int) *StatsMgr {
+// SpaceStats is the number of rows imported into a space.
+type SpaceStats struct {
+	Finished int64 `json:"finished"`
+	Failed   int64 `json:"failed"`
+	Skipped  int64 `json:"skipped"`
+}
+
+// Snapshot is the progress of an import task at a moment.
+type Snapshot struct {
+	Time       float64 `json:"time"`
+	Finished   int64   `json:"finished"`
+	Failed     int64   `json:"failed"`
+	Skipped    int64   `json:"skipped"`
+	LatencyAvg int64   `json:"latencyAvg"`
+	ReqAvg     int64   `json:"reqAvg"`
+	RowsPerSec float64 `json:"rowsPerSec"`
+	// Spaces are the rows grouped by space, only if more than one space is
+	// imported into
+	Spaces map[string]SpaceStats `json:"spaces,omitempty"`
+}
+
+func NewStatsMgr(numReadingFiles int, l *logger.Logger) *StatsMgr {
 	m := StatsMgr{
 		StatsCh:      make(chan base.Stats),
 		DoneCh:       make(chan bool),
+		FileDoneCh:   make(chan string, numReadingFiles),
 		NumFailed:    0,
 		totalCount:   0,
 		totalLatency: 0,
 		totalBatches: 0,
 		totalReqTime: 0.0,
+		startTime:    time.Now(),
+		spaces:       make(map[string]*SpaceStats),
+		logger:       l,
 	}
 	go m.startWorker(numReadingFiles)
 	return &m
@@ -38,38 +72,93 @@ func (s *StatsMgr) Close() {
 }
 
 func (s *StatsMgr) updateStat(stat base.Stats) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.totalBatches++
 	s.totalCount += int64(stat.BatchSize)
 	s.totalReqTime += stat.ReqTime
 	s.totalLatency += stat.Latency
+	s.space(stat.Space).Finished += int64(stat.BatchSize)
 }
 
 func (s *StatsMgr) updateFailed(stat base.Stats) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.totalBatches++
 	s.totalCount += int64(stat.BatchSize)
 	s.NumFailed += int64(stat.BatchSize)
+	sp := s.space(stat.Space)
+	sp.Finished += int64(stat.BatchSize)
+	sp.Failed += int64(stat.BatchSize)
+}
+
+func (s *StatsMgr) updateSkipped(stat base.Stats) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.numSkipped += int64(stat.BatchSize)
+	s.space(stat.Space).Skipped += int64(stat.BatchSize)
 }
 
-func (s *StatsMgr) print(prefix string, now time.Time) {
-	if s.totalCount == 0 {
+func (s *StatsMgr) space(name string) *SpaceStats {
+	sp, ok := s.spaces[name]
+	if !ok {
+		sp = &SpaceStats{}
+		s.spaces[name] = sp
+	}
+	return sp
+}
+
+func (s *StatsMgr) Snapshot() Snapshot {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	secs := time.Since(s.startTime).Seconds()
+	snapshot := Snapshot{
+		Time:     secs,
+		Finished: s.totalCount,
+		Failed:   s.NumFailed,
+		Skipped:  s.numSkipped,
+	}
+	if s.totalBatches > 0 {
+		snapshot.LatencyAvg = s.totalLatency / s.totalBatches
+		snapshot.ReqAvg = s.totalReqTime / s.totalBatches
+	}
+	if secs > 0 {
+		snapshot.RowsPerSec = float64(s.totalCount) / secs
+	}
+	if len(s.spaces) > 1 {
+		snapshot.Spaces = make(map[string]SpaceStats, len(s.spaces))
+		for name, sp := range s.spaces {
+			snapshot.Spaces[name] = *sp
+		}
+	}
+	return snapshot
+}
+
+func (s *StatsMgr) print(prefix string) {
+	snapshot := s.Snapshot()
+	if snapshot.Finished == 0 && snapshot.Skipped == 0 {
 		return
 	}
-	secs := time.Since(now).Seconds()
-	avgLatency := s.totalLatency / s.totalBatches
-	avgReq := s.totalReqTime / s.totalBatches
-	rps := float64(s.totalCount) / secs
-	logger.Infof("%s: Time(%.2fs), Finished(%d), Failed(%d), Latency AVG(%dus), Batches Req AVG(%dus), Rows AVG(%.2f/s)",
-		prefix, secs, s.totalCount, s.NumFailed, avgLatency, avgReq, rps)
+	s.logger.Infof("%s: Time(%.2fs), Finished(%d), Failed(%d), Skipped(%d), Latency AVG(%dus), Batches Req AVG(%dus), Rows AVG(%.2f/s)",
+		prefix, snapshot.Time, snapshot.Finished, snapshot.Failed, snapshot.Skipped, snapshot.LatencyAvg, snapshot.ReqAvg, snapshot.RowsPerSec)
+	var names []string
+	for name := range snapshot.Spaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sp := snapshot.Spaces[name]
+		s.logger.Infof("%s: Space(%s), Finished(%d), Failed(%d), Skipped(%d)", prefix, name, sp.Finished, sp.Failed, sp.Skipped)
+	}
 }
 
 func (s *StatsMgr) startWorker(numReadingFiles int) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
-	now := time.Now()
 	for {
 		select {
 		case <-ticker.C:
-			s.print("Tick", now)
+			s.print("Tick")
 		case stat, ok := <-s.StatsCh:
 			if !ok {
 				return
@@ -79,14 +168,21 @@ func (s *StatsMgr) startWorker(numReadingFiles int) {
 				s.updateStat(stat)
 			case base.FAILURE:
 				s.updateFailed(stat)
+			case base.SKIPPED:
+				s.updateSkipped(stat)
 			case base.FILEDONE:
-				s.print(fmt.Sprintf("Done(%s)", stat.Filename), now)
+				if stat.Mapping != "" {
+					s.print(fmt.Sprintf("Done(%s, %s)", stat.Filename, stat.Mapping))
+				} else {
+					s.print(fmt.Sprintf("Done(%s)", stat.Filename))
+				}
+				s.FileDoneCh <- stat.Filename
 				numReadingFiles--
 				if numReadingFiles == 0 {
 					s.DoneCh <- true
 				}
 			default:
-				logger.Fatalf("Error stats type: %s", stat.Type)
+				s.logger.Fatalf("Error stats type: %d", stat.Type)
 			}
 		}
 	}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"log"
+	"os"
+	"path"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+type auditLog struct {
+	logger *log.Logger
+}
+
+func newAuditLog(filePath string) (*auditLog, error) {
+	if err := os.MkdirAll(path.Dir(filePath), 0775); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{logger: log.New(file, "", log.LstdFlags)}, nil
+}
+
+// audit records who performed which action on which task or upload. Without an
+// audit log file, the records go to the importer log.
+func (w *WebServer) audit(p *Principal, a action, target string, result string) {
+	user, role := "-", Role("-")
+	if p != nil {
+		user, role = p.Name, p.Role
+	}
+	if w.auditLog != nil {
+		w.auditLog.logger.Printf("user=%q role=%s action=%s target=%s result=%q", user, role, a, target, result)
+	} else {
+		logger.Infof("[AUDIT] user=%q role=%s action=%s target=%s result=%q", user, role, a, target, result)
+	}
+}
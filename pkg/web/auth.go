@@ -0,0 +1,314 @@
+package web
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type Role string
+
+const (
+	RoleRead   Role = "read"
+	RoleSubmit Role = "submit"
+	RoleStop   Role = "stop"
+	RoleAdmin  Role = "admin"
+)
+
+type action string
+
+const (
+	actionRead   action = "read"
+	actionSubmit action = "submit"
+	actionStop   action = "stop"
+	actionUpload action = "upload"
+)
+
+func (r Role) isValid() bool {
+	switch r {
+	case RoleRead, RoleSubmit, RoleStop, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// allows reports whether the role may perform the action. Every role can read.
+func (r Role) allows(a action) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleSubmit:
+		return a == actionRead || a == actionSubmit || a == actionUpload
+	case RoleStop:
+		return a == actionRead || a == actionStop
+	case RoleRead:
+		return a == actionRead
+	default:
+		return false
+	}
+}
+
+type Principal struct {
+	Name string
+	Role Role
+}
+
+var errUnauthenticated = errors.New("invalid credentials")
+
+// Authenticator identifies the caller of a request. It returns nil principal
+// and nil error when the request carries no credentials it understands, so
+// that the next authenticator can try.
+type Authenticator interface {
+	Authenticate(req *http.Request) (*Principal, error)
+}
+
+type TokenCredential struct {
+	User  *string `json:"user" yaml:"user"`
+	Token *string `json:"token" yaml:"token"`
+	Role  *string `json:"role" yaml:"role"`
+}
+
+type BasicCredential struct {
+	User     *string `json:"user" yaml:"user"`
+	Password *string `json:"password" yaml:"password"`
+	Role     *string `json:"role" yaml:"role"`
+}
+
+type TLSConfig struct {
+	CertFile     *string           `json:"certFile" yaml:"certFile"`
+	KeyFile      *string           `json:"keyFile" yaml:"keyFile"`
+	ClientCAFile *string           `json:"clientCAFile" yaml:"clientCAFile"`
+	ClientRoles  map[string]string `json:"clientRoles" yaml:"clientRoles"`
+}
+
+type AuthConfig struct {
+	Tokens       []*TokenCredential `json:"tokens" yaml:"tokens"`
+	BasicAuth    []*BasicCredential `json:"basicAuth" yaml:"basicAuth"`
+	TLS          *TLSConfig         `json:"tls" yaml:"tls"`
+	AuditLogPath *string            `json:"auditLogPath" yaml:"auditLogPath"`
+}
+
+func ParseAuthConfig(filename string) (*AuthConfig, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf AuthConfig
+	if err = yaml.Unmarshal(content, &conf); err != nil {
+		return nil, err
+	}
+
+	if err = conf.validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+func parseRole(role *string, prefix string) (Role, error) {
+	if role == nil {
+		return "", fmt.Errorf("Please configure the role in: %s.role", prefix)
+	}
+	r := Role(strings.ToLower(*role))
+	if !r.isValid() {
+		return "", fmt.Errorf("Invalid %s.role: %s, only following values are supported: read, submit, stop, admin", prefix, *role)
+	}
+	return r, nil
+}
+
+func (c *AuthConfig) validate() error {
+	for i, t := range c.Tokens {
+		prefix := fmt.Sprintf("tokens[%d]", i)
+		if t.User == nil || t.Token == nil || *t.Token == "" {
+			return fmt.Errorf("Please configure user and token in: %s", prefix)
+		}
+		if _, err := parseRole(t.Role, prefix); err != nil {
+			return err
+		}
+	}
+
+	for i, b := range c.BasicAuth {
+		prefix := fmt.Sprintf("basicAuth[%d]", i)
+		if b.User == nil || b.Password == nil {
+			return fmt.Errorf("Please configure user and password in: %s", prefix)
+		}
+		if _, err := parseRole(b.Role, prefix); err != nil {
+			return err
+		}
+	}
+
+	if c.TLS != nil {
+		if c.TLS.CertFile == nil || c.TLS.KeyFile == nil {
+			return errors.New("Please configure certFile and keyFile in: tls")
+		}
+		for cn, role := range c.TLS.ClientRoles {
+			r := role
+			if _, err := parseRole(&r, fmt.Sprintf("tls.clientRoles.%s", cn)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Authenticators builds the authenticators enabled by the configuration.
+func (c *AuthConfig) Authenticators() []Authenticator {
+	var auths []Authenticator
+	if len(c.Tokens) > 0 {
+		a := &TokenAuthenticator{tokens: make(map[string]*Principal)}
+		for _, t := range c.Tokens {
+			role, _ := parseRole(t.Role, "")
+			a.tokens[*t.Token] = &Principal{Name: *t.User, Role: role}
+		}
+		auths = append(auths, a)
+	}
+	if len(c.BasicAuth) > 0 {
+		a := &BasicAuthenticator{users: make(map[string]*BasicCredential)}
+		for _, b := range c.BasicAuth {
+			a.users[*b.User] = b
+		}
+		auths = append(auths, a)
+	}
+	if c.TLS != nil && c.TLS.ClientCAFile != nil {
+		a := &CertAuthenticator{roles: make(map[string]Role)}
+		for cn, role := range c.TLS.ClientRoles {
+			r := role
+			a.roles[cn], _ = parseRole(&r, "")
+		}
+		auths = append(auths, a)
+	}
+	return auths
+}
+
+// ServerTLSConfig returns the tls configuration of http server. Client
+// certificates are verified against the configured CA when they are given.
+func (c *AuthConfig) ServerTLSConfig() (*tls.Config, error) {
+	if c.TLS == nil {
+		return nil, nil
+	}
+	conf := &tls.Config{}
+	if c.TLS.ClientCAFile != nil {
+		pem, err := ioutil.ReadFile(*c.TLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in tls.clientCAFile: %s", *c.TLS.ClientCAFile)
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return conf, nil
+}
+
+type TokenAuthenticator struct {
+	tokens map[string]*Principal
+}
+
+func (a *TokenAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	h := req.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return nil, nil
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	for t, p := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return p, nil
+		}
+	}
+	return nil, errUnauthenticated
+}
+
+type BasicAuthenticator struct {
+	users map[string]*BasicCredential
+}
+
+func (a *BasicAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	c, ok := a.users[user]
+	if !ok || subtle.ConstantTimeCompare([]byte(*c.Password), []byte(password)) != 1 {
+		return nil, errUnauthenticated
+	}
+	role, _ := parseRole(c.Role, "")
+	return &Principal{Name: user, Role: role}, nil
+}
+
+// CertAuthenticator maps the common name of a verified client certificate to a role.
+type CertAuthenticator struct {
+	roles map[string]Role
+}
+
+func (a *CertAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := a.roles[cn]
+	if !ok {
+		return nil, fmt.Errorf("no role is granted to client certificate %s", cn)
+	}
+	return &Principal{Name: cn, Role: role}, nil
+}
+
+// ParseRole parses the role, e.g. of the --anonymous-role flag.
+func ParseRole(role string) (Role, error) {
+	return parseRole(&role, "anonymous")
+}
+
+// anonymous returns the principal of the requests when no authentication is
+// configured, whose role is read unless AnonymousRole grants more.
+func (w *WebServer) anonymous() *Principal {
+	role := w.AnonymousRole
+	if role == "" {
+		role = RoleRead
+	}
+	return &Principal{Name: "anonymous", Role: role}
+}
+
+func (w *WebServer) authenticate(req *http.Request) (*Principal, error) {
+	if len(w.Authenticators) == 0 {
+		return w.anonymous(), nil
+	}
+	for _, a := range w.Authenticators {
+		p, err := a.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, errors.New("missing credentials")
+}
+
+// authorize wraps the handler so that it is only called for callers whose
+// role allows the action.
+func (w *WebServer) authorize(a action, handler func(http.ResponseWriter, *http.Request, *Principal)) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		p, err := w.authenticate(req)
+		if err != nil {
+			w.audit(nil, a, "-", fmt.Sprintf("denied: %s", err.Error()))
+			resp.Header().Set("WWW-Authenticate", `Basic realm="nebula-importer"`)
+			w.writeErr(resp, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !p.Role.allows(a) {
+			w.audit(p, a, "-", "denied: forbidden")
+			w.writeErr(resp, http.StatusForbidden, fmt.Sprintf("role %s is not allowed to %s", p.Role, a))
+			return
+		}
+		handler(resp, req, p)
+	}
+}
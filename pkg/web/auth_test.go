@@ -0,0 +1,206 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/cmd"
+)
+
+func newTestAuthConfig(t *testing.T) *AuthConfig {
+	user, token, tokenRole := "ci", "t0k3n", "submit"
+	basicUser, password, basicRole := "ops", "secret", "admin"
+	certFile, keyFile, caFile := "./server.crt", "./server.key", "./ca.crt"
+	conf := &AuthConfig{
+		Tokens:    []*TokenCredential{{User: &user, Token: &token, Role: &tokenRole}},
+		BasicAuth: []*BasicCredential{{User: &basicUser, Password: &password, Role: &basicRole}},
+		TLS: &TLSConfig{
+			CertFile:     &certFile,
+			KeyFile:      &keyFile,
+			ClientCAFile: &caFile,
+			ClientRoles:  map[string]string{"dashboard": "read"},
+		},
+	}
+	if err := conf.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+// withClientCert sets the verified client certificate of the request.
+func withClientCert(req *http.Request, cn string) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return req
+}
+
+func TestAuthenticate(t *testing.T) {
+	w := &WebServer{Authenticators: newTestAuthConfig(t).Authenticators()}
+	newRequest := func(header string) *http.Request {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		return req
+	}
+	basic := func(user, password string) *http.Request {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		req.SetBasicAuth(user, password)
+		return req
+	}
+	for _, c := range []struct {
+		req      *http.Request
+		expected string
+	}{
+		{newRequest("Bearer t0k3n"), "ci:submit"},
+		{newRequest("Bearer wrong"), "invalid credentials"},
+		{basic("ops", "secret"), "ops:admin"},
+		{basic("ops", "wrong"), "invalid credentials"},
+		{basic("nobody", "secret"), "invalid credentials"},
+		{withClientCert(newRequest(""), "dashboard"), "dashboard:read"},
+		{withClientCert(newRequest(""), "intruder"), "no role is granted to client certificate intruder"},
+		{newRequest(""), "missing credentials"},
+	} {
+		p, err := w.authenticate(c.req)
+		actual := ""
+		if err != nil {
+			actual = err.Error()
+		} else {
+			actual = p.Name + ":" + string(p.Role)
+		}
+		if actual != c.expected {
+			t.Errorf("Error principal of %v: %s, expected %s", c.req.Header, actual, c.expected)
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	for _, c := range []struct {
+		role    Role
+		allowed []action
+	}{
+		{RoleRead, []action{actionRead}},
+		{RoleSubmit, []action{actionRead, actionSubmit, actionUpload}},
+		{RoleStop, []action{actionRead, actionStop}},
+		{RoleAdmin, []action{actionRead, actionSubmit, actionStop, actionUpload}},
+	} {
+		for _, a := range []action{actionRead, actionSubmit, actionStop, actionUpload} {
+			expected := false
+			for _, allowed := range c.allowed {
+				if a == allowed {
+					expected = true
+				}
+			}
+			if c.role.allows(a) != expected {
+				t.Errorf("Role %s allows %s: %v, expected %v", c.role, a, !expected, expected)
+			}
+		}
+	}
+}
+
+// serve calls the handler of the action wrapped by authorize, and returns the
+// status code and whether the handler is called.
+func serve(w *WebServer, a action, req *http.Request) (int, bool) {
+	called := false
+	resp := httptest.NewRecorder()
+	w.authorize(a, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
+		called = true
+	})(resp, req)
+	return resp.Code, called
+}
+
+func TestAuthorizeAndAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	auditLog, err := newAuditLog(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &WebServer{
+		Authenticators: newTestAuthConfig(t).Authenticators(),
+		auditLog:       auditLog,
+		taskMgr:        newTaskMgr(),
+	}
+	withToken := func(method, path, body string) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0k3n")
+		return req
+	}
+
+	if code, called := serve(w, actionSubmit, withToken("POST", "/submit", "")); code != http.StatusOK || !called {
+		t.Errorf("Submit should be allowed for role submit: %d", code)
+	}
+	if code, called := serve(w, actionStop, withToken("PUT", "/stop", "")); code != http.StatusForbidden || called {
+		t.Errorf("Stop should be forbidden for role submit: %d", code)
+	}
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.SetBasicAuth("ops", "wrong")
+	if code, called := serve(w, actionRead, req); code != http.StatusUnauthorized || called {
+		t.Errorf("The wrong password should be unauthorized: %d", code)
+	}
+
+	w.taskMgr.put("1", &runningTask{runner: &cmd.Runner{}})
+	admin := &Principal{Name: "ops", Role: RoleAdmin}
+	for _, c := range []struct {
+		taskId string
+		code   int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+	} {
+		resp := httptest.NewRecorder()
+		w.stop(resp, httptest.NewRequest("PUT", "/stop", strings.NewReader(`{"taskId": "`+c.taskId+`"}`)), admin)
+		if resp.Code != c.code {
+			t.Errorf("Error status of stopping task %s: %d, expected %d", c.taskId, resp.Code, c.code)
+		}
+	}
+
+	b, err := ioutil.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{
+		`user="ci" role=submit action=stop target=- result="denied: forbidden"`,
+		`user="-" role=- action=read target=- result="denied: invalid credentials"`,
+		`user="ops" role=admin action=stop target=1 result="stopped"`,
+		`user="ops" role=admin action=stop target=2 result="not found"`,
+	} {
+		if !strings.Contains(string(b), record) {
+			t.Errorf("Record %s is not audited:\n%s", record, b)
+		}
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	w := &WebServer{}
+	if code, called := serve(w, actionRead, httptest.NewRequest("GET", "/tasks", nil)); code != http.StatusOK || !called {
+		t.Errorf("Anonymous requests should be allowed to read: %d", code)
+	}
+	if code, called := serve(w, actionSubmit, httptest.NewRequest("POST", "/submit", nil)); code != http.StatusForbidden || called {
+		t.Errorf("Anonymous requests should not be allowed to submit by default: %d", code)
+	}
+
+	role, err := ParseRole("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.AnonymousRole = role
+	if code, called := serve(w, actionSubmit, httptest.NewRequest("POST", "/submit", nil)); code != http.StatusOK || !called {
+		t.Errorf("Anonymous requests should be allowed to submit as admin: %d", code)
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Error("The unknown role should be rejected")
+	}
+}
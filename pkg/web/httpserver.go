@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/vesoft-inc/nebula-importer/pkg/cmd"
 	"github.com/vesoft-inc/nebula-importer/pkg/config"
@@ -14,11 +17,22 @@ import (
 )
 
 type WebServer struct {
-	Port     int
-	Callback string
-	server   *http.Server
-	taskMgr  *taskMgr
-	mux      sync.Mutex
+	Port           int
+	Callback       string
+	Auth           *AuthConfig
+	Authenticators []Authenticator
+	// AnonymousRole is the role of the requests when no authentication is
+	// configured, RoleRead by default
+	AnonymousRole   Role
+	Workspace       string
+	UploadRetention time.Duration
+	// MaxUploadSize limits the request body of each upload, no limit if it is 0
+	MaxUploadSize int64
+	server        *http.Server
+	taskMgr       *taskMgr
+	uploadMgr     *uploadMgr
+	auditLog      *auditLog
+	mux           sync.Mutex
 }
 
 var taskId uint64 = 0
@@ -35,23 +49,56 @@ func (w *WebServer) Start() {
 	m := http.NewServeMux()
 	w.taskMgr = newTaskMgr()
 
-	m.HandleFunc("/submit", func(resp http.ResponseWriter, req *http.Request) {
+	if w.Auth != nil {
+		w.Authenticators = append(w.Authenticators, w.Auth.Authenticators()...)
+		if w.Auth.AuditLogPath != nil {
+			auditLog, err := newAuditLog(*w.Auth.AuditLogPath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			w.auditLog = auditLog
+		}
+	}
+	if len(w.Authenticators) == 0 {
+		logger.Warnf("No authentication is configured, every request to http server is allowed as role %s", w.anonymous().Role)
+	}
+
+	if w.Workspace != "" {
+		uploadMgr, err := newUploadMgr(w.Workspace, w.UploadRetention, w.MaxUploadSize)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		w.uploadMgr = uploadMgr
+
+		m.HandleFunc("/upload", w.authorize(actionUpload, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
+			switch req.Method {
+			case "POST":
+				w.upload(resp, req, p)
+			case "DELETE":
+				w.deleteUpload(resp, req, p)
+			default:
+				w.badRequest(resp, "HTTP method must be POST or DELETE")
+			}
+		}))
+	}
+
+	m.HandleFunc("/submit", w.authorize(actionSubmit, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
 		if req.Method == "POST" {
-			w.submit(resp, req)
+			w.submit(resp, req, p)
 		} else {
 			w.badRequest(resp, "HTTP method must be POST")
 		}
-	})
+	}))
 
-	m.HandleFunc("/stop", func(resp http.ResponseWriter, req *http.Request) {
+	m.HandleFunc("/stop", w.authorize(actionStop, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
 		if req.Method == "PUT" {
-			w.stop(resp, req)
+			w.stop(resp, req, p)
 		} else {
 			w.badRequest(resp, "HTTP method must be PUT")
 		}
-	})
+	}))
 
-	m.HandleFunc("/tasks", func(resp http.ResponseWriter, req *http.Request) {
+	m.HandleFunc("/tasks", w.authorize(actionRead, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
 		if req.Method == "GET" {
 			keys := w.taskMgr.keys()
 			var tasks struct {
@@ -69,7 +116,15 @@ func (w *WebServer) Start() {
 		} else {
 			w.badRequest(resp, "HTTP method must be GET")
 		}
-	})
+	}))
+
+	m.HandleFunc("/logs", w.authorize(actionRead, func(resp http.ResponseWriter, req *http.Request, p *Principal) {
+		if req.Method == "GET" {
+			w.logs(resp, req, p)
+		} else {
+			w.badRequest(resp, "HTTP method must be GET")
+		}
+	}))
 
 	w.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", w.Port),
@@ -81,7 +136,16 @@ func (w *WebServer) Start() {
 }
 
 func (w *WebServer) listenAndServe() {
-	if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if w.Auth != nil && w.Auth.TLS != nil {
+		if w.server.TLSConfig, err = w.Auth.ServerTLSConfig(); err != nil {
+			logger.Fatal(err)
+		}
+		err = w.server.ListenAndServeTLS(*w.Auth.TLS.CertFile, *w.Auth.TLS.KeyFile)
+	} else {
+		err = w.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logger.Fatal(err)
 	}
 }
@@ -112,20 +176,20 @@ func (w *WebServer) callback(body *respBody) {
 	}
 }
 
-func (w *WebServer) stopRunner(taskId string) {
-	runner := w.taskMgr.get(taskId)
-	if runner == nil {
-		return
+// stopRunner stops the task, and reports whether the task is running.
+func (w *WebServer) stopRunner(taskId string) bool {
+	t := w.taskMgr.get(taskId)
+	if t == nil {
+		return false
 	}
 
-	for _, r := range runner.Readers {
-		r.Stop()
-	}
+	t.runner.Stop()
 
 	logger.Infof("Task %s stopped.", taskId)
+	return true
 }
 
-func (w *WebServer) stop(resp http.ResponseWriter, req *http.Request) {
+func (w *WebServer) stop(resp http.ResponseWriter, req *http.Request, p *Principal) {
 	if req.Body == nil {
 		w.badRequest(resp, "nil request body")
 		return
@@ -140,10 +204,17 @@ func (w *WebServer) stop(resp http.ResponseWriter, req *http.Request) {
 
 	if strings.ToLower(task.TaskId) == "all" {
 		for _, k := range w.taskMgr.keys() {
-			w.stopRunner(k)
+			// The task may finish after the keys are listed
+			if w.stopRunner(k) {
+				w.audit(p, actionStop, k, "stopped")
+			}
 		}
+	} else if w.stopRunner(task.TaskId) {
+		w.audit(p, actionStop, task.TaskId, "stopped")
 	} else {
-		w.stopRunner(task.TaskId)
+		w.audit(p, actionStop, task.TaskId, "not found")
+		w.writeErr(resp, http.StatusNotFound, fmt.Sprintf("Task %s doesn't exist", task.TaskId))
+		return
 	}
 
 	resp.WriteHeader(http.StatusOK)
@@ -153,7 +224,10 @@ func (w *WebServer) stop(resp http.ResponseWriter, req *http.Request) {
 }
 
 func (w *WebServer) badRequest(resp http.ResponseWriter, msg string) {
-	resp.WriteHeader(http.StatusOK)
+	w.writeErr(resp, http.StatusOK, msg)
+}
+
+func (w *WebServer) writeErr(resp http.ResponseWriter, status int, msg string) {
 	t := errResult{
 		ErrCode: 1,
 		ErrMsg:  msg,
@@ -162,38 +236,80 @@ func (w *WebServer) badRequest(resp http.ResponseWriter, msg string) {
 	if b, err := json.Marshal(t); err != nil {
 		logger.Error(err)
 	} else {
-		resp.WriteHeader(http.StatusOK)
+		resp.WriteHeader(status)
 		if _, err = resp.Write(b); err != nil {
 			logger.Error(err)
 		}
 	}
 }
 
-func (w *WebServer) submit(resp http.ResponseWriter, req *http.Request) {
+func (w *WebServer) submit(resp http.ResponseWriter, req *http.Request, p *Principal) {
 	if req.Body == nil {
 		w.badRequest(resp, "nil request body")
 		return
 	}
 	defer req.Body.Close()
 
+	// Unknown keys are rejected unless strict=false is given for the configs of newer versions
+	strict := true
+	if s := req.URL.Query().Get("strict"); s != "" {
+		var err error
+		if strict, err = strconv.ParseBool(s); err != nil {
+			w.badRequest(resp, fmt.Sprintf("Invalid strict: %s", s))
+			return
+		}
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.badRequest(resp, err.Error())
+		return
+	}
 	var conf config.YAMLConfig
-	if err := json.NewDecoder(req.Body).Decode(&conf); err != nil {
+	if err := config.DecodeJSON(body, &conf, strict); err != nil {
+		w.audit(p, actionSubmit, "-", fmt.Sprintf("rejected: %s", err.Error()))
 		w.badRequest(resp, err.Error())
 		return
 	}
 
+	// Secrets of the importer host must not be sent to a graph address chosen by remote clients
+	if conf.NebulaClientSettings != nil && conf.NebulaClientSettings.Connection != nil && conf.NebulaClientSettings.Connection.HasSecretReference() {
+		msg := "passwordFile and passwordFromEnv are only supported in configuration files"
+		w.audit(p, actionSubmit, "-", fmt.Sprintf("rejected: %s", msg))
+		w.badRequest(resp, msg)
+		return
+	}
+
+	var uploads []string
+	if w.uploadMgr != nil {
+		var err error
+		if uploads, err = w.uploadMgr.resolve(&conf); err != nil {
+			w.audit(p, actionSubmit, "-", fmt.Sprintf("rejected: %s", err.Error()))
+			w.badRequest(resp, err.Error())
+			return
+		}
+	}
+
 	if err := conf.ValidateAndReset(""); err != nil {
+		if w.uploadMgr != nil {
+			w.uploadMgr.release(uploads)
+		}
+		w.audit(p, actionSubmit, "-", fmt.Sprintf("rejected: %s", err.Error()))
 		w.badRequest(resp, err.Error())
 		return
 	}
 
-	runner := &cmd.Runner{}
 	tid := w.newTaskId()
-	w.taskMgr.put(tid, runner)
+	logs := newLogStream()
+	runner := &cmd.Runner{LogWriter: logs}
+	logPath := taskLogPath(*conf.LogPath, tid)
+	conf.LogPath = &logPath
+	w.taskMgr.put(tid, &runningTask{runner: runner, logs: logs})
 	t := task{
 		errResult: errResult{ErrCode: 0},
 		TaskId:    tid,
 	}
+	w.audit(p, actionSubmit, tid, "submitted")
 
 	go func(tid string) {
 		runner.Run(&conf)
@@ -217,6 +333,10 @@ func (w *WebServer) submit(resp http.ResponseWriter, req *http.Request) {
 		}
 		w.callback(&body)
 		w.taskMgr.del(tid)
+		logs.close()
+		if w.uploadMgr != nil {
+			w.uploadMgr.release(uploads)
+		}
 	}(tid)
 
 	if b, err := json.Marshal(t); err != nil {
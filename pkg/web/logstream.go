@@ -0,0 +1,184 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+const logBacklogSize = 1000
+
+// logStream is the log writer of a task. It keeps the latest lines for late
+// subscribers and fans out new lines to all subscribers.
+type logStream struct {
+	backlog     []string
+	partial     []byte
+	subscribers map[chan string]bool
+	closed      bool
+	mux         sync.Mutex
+}
+
+func newLogStream() *logStream {
+	return &logStream{
+		subscribers: make(map[chan string]bool),
+	}
+}
+
+func (s *logStream) Write(p []byte) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(s.partial[:i])
+		s.partial = s.partial[i+1:]
+		s.backlog = append(s.backlog, line)
+		if len(s.backlog) > logBacklogSize {
+			s.backlog = s.backlog[len(s.backlog)-logBacklogSize:]
+		}
+		for ch := range s.subscribers {
+			select {
+			case ch <- line:
+			default:
+				// Drop lines for slow subscribers rather than blocking the import
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (s *logStream) subscribe() (chan string, []string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ch := make(chan string, logBacklogSize)
+	if s.closed {
+		close(ch)
+	} else {
+		s.subscribers[ch] = true
+	}
+	return ch, append([]string(nil), s.backlog...)
+}
+
+func (s *logStream) unsubscribe(ch chan string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (s *logStream) close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// taskLogPath makes the log file path of one task from the configured one,
+// e.g. /tmp/nebula-importer.log to /tmp/nebula-importer.task-3.log.
+func taskLogPath(path string, taskId string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.task-%s%s", strings.TrimSuffix(path, ext), taskId, ext)
+}
+
+func writeEvent(resp http.ResponseWriter, event string, data string) error {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("event: %s\n", event))
+	for _, line := range strings.Split(data, "\n") {
+		builder.WriteString(fmt.Sprintf("data: %s\n", line))
+	}
+	builder.WriteString("\n")
+	_, err := resp.Write([]byte(builder.String()))
+	return err
+}
+
+// logs streams the log lines and periodic stats snapshots of a running task
+// as Server-Sent Events until the task finishes or the client goes away.
+func (w *WebServer) logs(resp http.ResponseWriter, req *http.Request, p *Principal) {
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		w.badRequest(resp, "Streaming is not supported")
+		return
+	}
+
+	tid := req.URL.Query().Get("taskId")
+	t := w.taskMgr.get(tid)
+	if t == nil {
+		w.badRequest(resp, fmt.Sprintf("Task %s doesn't exist", tid))
+		return
+	}
+
+	interval := 5 * time.Second
+	if s := req.URL.Query().Get("interval"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs <= 0 {
+			w.badRequest(resp, fmt.Sprintf("Invalid interval: %s", s))
+			return
+		}
+		interval = time.Duration(secs) * time.Second
+	}
+
+	ch, backlog := t.logs.subscribe()
+	defer t.logs.unsubscribe(ch)
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Connection", "keep-alive")
+	resp.WriteHeader(http.StatusOK)
+
+	for _, line := range backlog {
+		if err := writeEvent(resp, "log", line); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-req.Context().Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				if err := writeEvent(resp, "done", tid); err != nil {
+					logger.Error(err)
+				}
+				flusher.Flush()
+				return
+			}
+			err = writeEvent(resp, "log", line)
+		case <-ticker.C:
+			snapshot, ok := t.runner.Stats()
+			if !ok {
+				continue
+			}
+			b, e := json.Marshal(snapshot)
+			if e != nil {
+				logger.Error(e)
+				continue
+			}
+			err = writeEvent(resp, "stats", string(b))
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/cmd"
+)
+
+func TestLogStream(t *testing.T) {
+	s := newLogStream()
+	s.Write([]byte("line 1\nline"))
+	first, backlog := s.subscribe()
+	if !reflect.DeepEqual(backlog, []string{"line 1"}) {
+		t.Errorf("Error backlog: %q", backlog)
+	}
+	second, _ := s.subscribe()
+	s.Write([]byte(" 2\n"))
+	for _, ch := range []chan string{first, second} {
+		if line := <-ch; line != "line 2" {
+			t.Errorf("Error line of subscriber: %q", line)
+		}
+	}
+
+	s.unsubscribe(first)
+	if _, ok := <-first; ok {
+		t.Error("The channel of the removed subscriber should be closed")
+	}
+	s.Write([]byte("line 3\n"))
+	if line := <-second; line != "line 3" {
+		t.Errorf("Error line of subscriber: %q", line)
+	}
+
+	s.close()
+	if _, ok := <-second; ok {
+		t.Error("The channels should be closed with the stream")
+	}
+	late, backlog := s.subscribe()
+	if _, ok := <-late; ok || len(backlog) != 3 {
+		t.Errorf("The late subscriber should only get the backlog: %q", backlog)
+	}
+}
+
+// numSubscribers returns the number of the subscribers of the stream.
+func numSubscribers(s *logStream) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.subscribers)
+}
+
+// readEvent reads an event of the stream, the lines up to the blank line.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Fail to read the event: %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	logs := newLogStream()
+	logs.Write([]byte("started\n"))
+	w := &WebServer{taskMgr: newTaskMgr()}
+	w.taskMgr.put("1", &runningTask{runner: &cmd.Runner{}, logs: logs})
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		w.logs(resp, req, nil)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/logs?taskId=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Error content type: %s", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+	if e := readEvent(t, r); e != "event: log\ndata: started\n" {
+		t.Errorf("Error event of the backlog: %q", e)
+	}
+	logs.Write([]byte("importing\n"))
+	if e := readEvent(t, r); e != "event: log\ndata: importing\n" {
+		t.Errorf("Error event of the new line: %q", e)
+	}
+
+	// The subscriber is removed once the client goes away
+	resp.Body.Close()
+	for i := 0; numSubscribers(logs) > 0; i++ {
+		if i == 100 {
+			t.Fatal("The subscriber should be removed after the client disconnects")
+		}
+		logs.Write([]byte("ping\n"))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err = http.Get(server.URL + "/logs?taskId=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r = bufio.NewReader(resp.Body)
+	for i := 0; numSubscribers(logs) == 0; i++ {
+		if i == 100 {
+			t.Fatal("The client should subscribe the logs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	logs.close()
+	var e string
+	for e = readEvent(t, r); strings.HasPrefix(e, "event: log\n"); e = readEvent(t, r) {
+	}
+	if e != "event: done\ndata: 1\n" {
+		t.Errorf("Error event of the finished task: %q", e)
+	}
+
+	resp, err = http.Get(server.URL + "/logs?taskId=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result errResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.ErrCode == 0 {
+		t.Errorf("The logs of the unknown task should not be streamed: %+v, %v", result, err)
+	}
+}
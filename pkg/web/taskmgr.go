@@ -7,14 +7,19 @@ import (
 	"github.com/vesoft-inc/nebula-importer/pkg/logger"
 )
 
+type runningTask struct {
+	runner *cmd.Runner
+	logs   *logStream
+}
+
 type taskMgr struct {
-	tasks map[string]*cmd.Runner
+	tasks map[string]*runningTask
 	mux   sync.Mutex
 }
 
 func newTaskMgr() *taskMgr {
 	return &taskMgr{
-		tasks: make(map[string]*cmd.Runner),
+		tasks: make(map[string]*runningTask),
 	}
 }
 
@@ -28,13 +33,13 @@ func (m *taskMgr) keys() []string {
 	return keys
 }
 
-func (m *taskMgr) put(k string, r *cmd.Runner) {
+func (m *taskMgr) put(k string, r *runningTask) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	m.tasks[k] = r
 }
 
-func (m *taskMgr) get(k string) *cmd.Runner {
+func (m *taskMgr) get(k string) *runningTask {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	if v, ok := m.tasks[k]; !ok {
@@ -0,0 +1,349 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+	"github.com/vesoft-inc/nebula-importer/pkg/logger"
+)
+
+// UploadScheme prefixes the file path in submitted configuration which
+// refers to uploaded data, e.g. upload://<id> or upload://<id>/<filename>.
+const UploadScheme = "upload://"
+
+type upload struct {
+	dir  string
+	refs int
+	// writes is the number of the files being written
+	writes   int
+	lastUsed time.Time
+}
+
+// uploadMgr stores the uploaded data files in the workspace, one directory
+// per upload, and removes them once they have been unused longer than retention.
+type uploadMgr struct {
+	workspace string
+	retention time.Duration
+	// maxSize limits the request body of each upload, no limit if it is 0
+	maxSize int64
+	uploads map[string]*upload
+	mux     sync.Mutex
+}
+
+func newUploadMgr(workspace string, retention time.Duration, maxSize int64) (*uploadMgr, error) {
+	if err := os.MkdirAll(workspace, 0775); err != nil {
+		return nil, err
+	}
+	m := &uploadMgr{
+		workspace: workspace,
+		retention: retention,
+		maxSize:   maxSize,
+		uploads:   make(map[string]*upload),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	go m.startJanitor()
+	return m, nil
+}
+
+// load restores the uploads left in the workspace by the last run, which are
+// removed once they expire as well.
+func (m *uploadMgr) load() error {
+	infos, err := ioutil.ReadDir(m.workspace)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if !info.IsDir() || !isUploadId(info.Name()) {
+			continue
+		}
+		m.uploads[info.Name()] = &upload{
+			dir:      filepath.Join(m.workspace, info.Name()),
+			lastUsed: info.ModTime(),
+		}
+	}
+	if len(m.uploads) > 0 {
+		logger.Infof("%d uploads are restored from workspace %s", len(m.uploads), m.workspace)
+	}
+	return nil
+}
+
+func newUploadId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isUploadId(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 16
+}
+
+// getOrCreate returns the upload of id, creating a new upload if id is empty.
+func (m *uploadMgr) getOrCreate(id string) (string, *upload, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if id != "" {
+		if u, ok := m.uploads[id]; ok {
+			u.lastUsed = time.Now()
+			return id, u, nil
+		}
+		return "", nil, fmt.Errorf("Upload %s doesn't exist", id)
+	}
+
+	id, err := newUploadId()
+	if err != nil {
+		return "", nil, err
+	}
+	u := &upload{
+		dir:      filepath.Join(m.workspace, id),
+		lastUsed: time.Now(),
+	}
+	if err := os.MkdirAll(u.dir, 0775); err != nil {
+		return "", nil, err
+	}
+	m.uploads[id] = u
+	return id, u, nil
+}
+
+// save writes the file of the upload. The files of the upload used by running
+// tasks can't be written, and the file is removed if it fails to be written,
+// e.g. the request body is too large.
+func (m *uploadMgr) save(id string, u *upload, name string, r io.Reader) (string, error) {
+	name = filepath.Base(name)
+	if name == "." || name == ".." || name == string(filepath.Separator) || name == "" {
+		return "", fmt.Errorf("Invalid upload file name: %s", name)
+	}
+
+	m.mux.Lock()
+	if u.refs > 0 {
+		m.mux.Unlock()
+		return "", fmt.Errorf("Upload %s is used by %d running tasks", id, u.refs)
+	}
+	u.writes++
+	m.mux.Unlock()
+	defer func() {
+		m.mux.Lock()
+		u.writes--
+		u.lastUsed = time.Now()
+		m.mux.Unlock()
+	}()
+
+	path := filepath.Join(u.dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(f, r); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		if e := os.Remove(path); e != nil {
+			logger.Error(e)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// resolve replaces upload references in the file and lookup paths of
+// configuration with local paths and holds the referred uploads until release
+// is called.
+func (m *uploadMgr) resolve(conf *config.YAMLConfig) ([]string, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var ids []string
+	resolvePath := func(path *string, key string) error {
+		if path == nil || !strings.HasPrefix(*path, UploadScheme) {
+			return nil
+		}
+		ref := strings.SplitN(strings.TrimPrefix(*path, UploadScheme), "/", 2)
+		u, ok := m.uploads[ref[0]]
+		if !ok {
+			return fmt.Errorf("Upload %s in %s doesn't exist", ref[0], key)
+		}
+		if u.writes > 0 {
+			return fmt.Errorf("Upload %s in %s is being written", ref[0], key)
+		}
+		local := u.dir
+		if len(ref) > 1 && ref[1] != "" {
+			local = filepath.Join(u.dir, filepath.Base(ref[1]))
+		}
+		*path = local
+		u.refs++
+		ids = append(ids, ref[0])
+		return nil
+	}
+	for i, f := range conf.Files {
+		if f == nil {
+			continue
+		}
+		err := resolvePath(f.Path, fmt.Sprintf("files[%d].path", i))
+		for j := 0; err == nil && j < len(f.Lookups); j++ {
+			if l := f.Lookups[j]; l != nil {
+				err = resolvePath(l.Path, fmt.Sprintf("files[%d].lookups[%d].path", i, j))
+			}
+		}
+		if err != nil {
+			m.releaseLocked(ids)
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (m *uploadMgr) release(ids []string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.releaseLocked(ids)
+}
+
+func (m *uploadMgr) releaseLocked(ids []string) {
+	for _, id := range ids {
+		if u, ok := m.uploads[id]; ok {
+			u.refs--
+			u.lastUsed = time.Now()
+		}
+	}
+}
+
+func (m *uploadMgr) remove(id string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	u, ok := m.uploads[id]
+	if !ok {
+		return fmt.Errorf("Upload %s doesn't exist", id)
+	}
+	if u.refs > 0 {
+		return fmt.Errorf("Upload %s is used by %d running tasks", id, u.refs)
+	}
+	if u.writes > 0 {
+		return fmt.Errorf("Upload %s is being written", id)
+	}
+	delete(m.uploads, id)
+	return os.RemoveAll(u.dir)
+}
+
+func (m *uploadMgr) startJanitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mux.Lock()
+		for id, u := range m.uploads {
+			if u.refs == 0 && u.writes == 0 && time.Since(u.lastUsed) > m.retention {
+				delete(m.uploads, id)
+				if err := os.RemoveAll(u.dir); err != nil {
+					logger.Error(err)
+				} else {
+					logger.Infof("Upload %s expired and has been removed", id)
+				}
+			}
+		}
+		m.mux.Unlock()
+	}
+}
+
+type uploadResult struct {
+	errResult
+	UploadId string   `json:"uploadId"`
+	Files    []string `json:"files"`
+}
+
+// upload accepts data files either as multipart form, or as the raw request
+// body named by the name query parameter. Files can be appended to an
+// existing upload by the id query parameter.
+func (w *WebServer) upload(resp http.ResponseWriter, req *http.Request, p *Principal) {
+	if req.Body == nil {
+		w.badRequest(resp, "nil request body")
+		return
+	}
+	defer req.Body.Close()
+	if w.uploadMgr.maxSize > 0 {
+		req.Body = http.MaxBytesReader(resp, req.Body, w.uploadMgr.maxSize)
+	}
+
+	id, u, err := w.uploadMgr.getOrCreate(req.URL.Query().Get("id"))
+	if err != nil {
+		w.badRequest(resp, err.Error())
+		return
+	}
+
+	var files []string
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		reader, err := req.MultipartReader()
+		if err != nil {
+			w.badRequest(resp, err.Error())
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.badRequest(resp, err.Error())
+				return
+			}
+			if part.FileName() == "" {
+				continue
+			}
+			name, err := w.uploadMgr.save(id, u, part.FileName(), part)
+			if err != nil {
+				w.badRequest(resp, err.Error())
+				return
+			}
+			files = append(files, name)
+		}
+	} else {
+		name, err := w.uploadMgr.save(id, u, req.URL.Query().Get("name"), req.Body)
+		if err != nil {
+			w.badRequest(resp, err.Error())
+			return
+		}
+		files = append(files, name)
+	}
+
+	w.audit(p, actionUpload, id, fmt.Sprintf("uploaded %s", strings.Join(files, ",")))
+	result := uploadResult{
+		errResult: errResult{ErrCode: 0},
+		UploadId:  id,
+		Files:     files,
+	}
+	if b, err := json.Marshal(result); err != nil {
+		w.badRequest(resp, err.Error())
+	} else {
+		resp.WriteHeader(http.StatusOK)
+		if _, err := resp.Write(b); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func (w *WebServer) deleteUpload(resp http.ResponseWriter, req *http.Request, p *Principal) {
+	id := req.URL.Query().Get("id")
+	if err := w.uploadMgr.remove(id); err != nil {
+		w.badRequest(resp, err.Error())
+		return
+	}
+	w.audit(p, actionUpload, id, "removed")
+	resp.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintln(resp, "OK"); err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vesoft-inc/nebula-importer/pkg/config"
+)
+
+// postUpload posts the body to the upload handler.
+func postUpload(t *testing.T, w *WebServer, query string, contentType string, body []byte) uploadResult {
+	req := httptest.NewRequest("POST", "/upload?"+query, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp := httptest.NewRecorder()
+	w.upload(resp, req, &Principal{Name: "ci", Role: RoleSubmit})
+	var result uploadResult
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Error response %q: %v", resp.Body.String(), err)
+	}
+	return result
+}
+
+func TestUpload(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "nebula-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace)
+	uploadMgr, err := newUploadMgr(workspace, time.Hour, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &WebServer{uploadMgr: uploadMgr}
+
+	result := postUpload(t, w, "name=a.csv", "", []byte("1,2\n"))
+	if result.ErrCode != 0 || len(result.Files) != 1 || result.Files[0] != "a.csv" {
+		t.Fatalf("Fail to upload: %+v", result)
+	}
+	id := result.UploadId
+	dir := filepath.Join(workspace, id)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", "../b.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("3,4\n"))
+	mw.Close()
+	result = postUpload(t, w, "id="+id, mw.FormDataContentType(), form.Bytes())
+	if result.ErrCode != 0 || result.UploadId != id || len(result.Files) != 1 || result.Files[0] != "b.csv" {
+		t.Fatalf("Fail to upload by multipart form: %+v", result)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "b.csv")); err != nil || string(b) != "3,4\n" {
+		t.Errorf("Error uploaded file: %q, %v", b, err)
+	}
+
+	result = postUpload(t, w, "id="+id+"&name=c.csv", "", bytes.Repeat([]byte("1,2\n"), 300))
+	if result.ErrCode == 0 || !strings.Contains(result.ErrMsg, "too large") {
+		t.Errorf("The body exceeding the size limit should be rejected: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.csv")); !os.IsNotExist(err) {
+		t.Errorf("The file exceeding the size limit should be removed: %v", err)
+	}
+
+	path, lookupPath, missing := UploadScheme+id+"/a.csv", UploadScheme+id+"/b.csv", UploadScheme+"missing/b.csv"
+	conf := &config.YAMLConfig{Files: []*config.File{{Path: &path, Lookups: []*config.Lookup{{Path: &missing}}}}}
+	if _, err := uploadMgr.resolve(conf); err == nil || !strings.Contains(err.Error(), "files[0].lookups[0].path") {
+		t.Errorf("The missing upload of the lookup should be rejected: %v", err)
+	}
+	if refs := uploadMgr.uploads[id].refs; refs != 0 {
+		t.Errorf("The uploads should be released when the resolving fails: %d", refs)
+	}
+
+	path = UploadScheme + id + "/a.csv"
+	conf = &config.YAMLConfig{Files: []*config.File{{Path: &path, Lookups: []*config.Lookup{{Path: &lookupPath}}}}}
+	ids, err := uploadMgr.resolve(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Files[0].Path != filepath.Join(dir, "a.csv") {
+		t.Errorf("Error resolved path: %s", *conf.Files[0].Path)
+	}
+	if *conf.Files[0].Lookups[0].Path != filepath.Join(dir, "b.csv") {
+		t.Errorf("Error resolved lookup path: %s", *conf.Files[0].Lookups[0].Path)
+	}
+	result = postUpload(t, w, "id="+id+"&name=a.csv", "", []byte("5,6\n"))
+	if result.ErrCode == 0 || !strings.Contains(result.ErrMsg, "used by 2 running tasks") {
+		t.Errorf("The upload used by tasks should not be written: %+v", result)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "a.csv")); err != nil || string(b) != "1,2\n" {
+		t.Errorf("The file used by tasks is changed: %q, %v", b, err)
+	}
+	if err := uploadMgr.remove(id); err == nil {
+		t.Error("The upload used by tasks should not be removed")
+	}
+	uploadMgr.release(ids)
+	if result = postUpload(t, w, "id="+id+"&name=a.csv", "", []byte("5,6\n")); result.ErrCode != 0 {
+		t.Errorf("Fail to upload after the task finishes: %+v", result)
+	}
+
+	restarted, err := newUploadMgr(workspace, time.Hour, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = UploadScheme + id
+	conf = &config.YAMLConfig{Files: []*config.File{{Path: &path}}}
+	if _, err := restarted.resolve(conf); err != nil || *conf.Files[0].Path != dir {
+		t.Errorf("The upload should be restored after restart: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/upload?id="+id, nil)
+	resp := httptest.NewRecorder()
+	w.deleteUpload(resp, req, nil)
+	if resp.Code != http.StatusOK {
+		t.Errorf("Fail to remove the upload: %s", resp.Body.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("The upload should be removed: %v", err)
+	}
+}